@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// ---------------- collation ----------------
+// Режимы сравнения имён:
+//   - unicode — правила Unicode для языка из config.Locale (или $LANG):
+//     числа по значению, регистр и диакритика учитываются в последнюю очередь;
+//   - natural — числа по значению, регистр без учёта, остальное по кодам символов;
+//   - bytes   — побайтово, как sort.Strings.
+var collationModes = []string{"unicode", "natural", "bytes"}
+
+var (
+	// nameCollator используется только из горутины интерфейса: Collator не потокобезопасен
+	nameCollator *collate.Collator
+	// collationGen меняется при смене правил, чтобы устаревшие ключи пересчитывались
+	collationGen = 1
+)
+
+func collationTag() language.Tag {
+	loc := config.Locale
+	for _, env := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if loc != "" {
+			break
+		}
+		loc = os.Getenv(env)
+	}
+	// ru_RU.UTF-8 -> ru-RU
+	if i := strings.IndexAny(loc, ".@"); i >= 0 {
+		loc = loc[:i]
+	}
+	loc = strings.ReplaceAll(loc, "_", "-")
+	if loc == "" || loc == "C" || loc == "POSIX" {
+		return language.Und
+	}
+	tag, err := language.Parse(loc)
+	if err != nil {
+		return language.Und
+	}
+	return tag
+}
+
+func newNameCollator() *collate.Collator {
+	return collate.New(collationTag(), collate.Numeric, collate.IgnoreCase)
+}
+
+// setupCollation применяет config.Collation; вызывается при старте и при смене режима
+func setupCollation() {
+	collationGen++
+	nameCollator = nil
+	if config.Collation == "unicode" {
+		nameCollator = newNameCollator()
+	}
+}
+
+func nextCollation(mode string) string {
+	for i, m := range collationModes {
+		if m == mode {
+			return collationModes[(i+1)%len(collationModes)]
+		}
+	}
+	return collationModes[0]
+}
+
+// entryKeyer считает ключи сравнения; у каждой горутины загрузки свой
+type entryKeyer struct {
+	c   *collate.Collator
+	buf collate.Buffer
+	gen int
+}
+
+func newEntryKeyer(c *collate.Collator, gen int) *entryKeyer {
+	if c == nil {
+		return nil
+	}
+	return &entryKeyer{c: c, gen: gen}
+}
+
+func (k *entryKeyer) fill(e *Entry) {
+	if k == nil {
+		return
+	}
+	key := k.c.KeyFromString(&k.buf, e.name)
+	e.key = append([]byte(nil), key...)
+	e.keyGen = k.gen
+	k.buf.Reset()
+}
+
+// ensureKeys досчитывает ключи, которых нет или которые устарели
+func ensureKeys(entries []Entry) {
+	if nameCollator == nil {
+		return
+	}
+	k := newEntryKeyer(nameCollator, collationGen)
+	for i := range entries {
+		if entries[i].keyGen != collationGen {
+			k.fill(&entries[i])
+		}
+	}
+}
+
+// compareEntryNames сравнивает имена по выбранным правилам; при unicode ключи
+// должны быть подготовлены ensureKeys
+func compareEntryNames(a, b *Entry) int {
+	switch config.Collation {
+	case "bytes":
+		return strings.Compare(a.name, b.name)
+	case "natural":
+		return compareNames(a.name, b.name)
+	}
+	if c := bytes.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return strings.Compare(a.name, b.name)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------- detailed view ----------------
+type column struct {
+	key   string // имя колонки в конфиге, заполняется layoutColumns
+	title string
+	width int
+	value func(e *Entry) string
+}
+
+const minNameWidth = 12
+
+var columnDefs = map[string]column{
+	"size": {"", "Size", 7, func(e *Entry) string {
+		if e.isDir() {
+			return "-"
+		}
+		return humanSize(e.size)
+	}},
+	"mtime": {"", "Modified", 16, func(e *Entry) string { return formatTime(e.modTime.IsZero(), e.modTime.Format("2006-01-02 15:04")) }},
+	"ctime": {"", "Changed", 16, func(e *Entry) string { return formatTime(e.ctime.IsZero(), e.ctime.Format("2006-01-02 15:04")) }},
+	"perms": {"", "Perms", 10, func(e *Entry) string { return e.mode.String() }},
+	"owner": {"", "Owner", 8, func(e *Entry) string { return e.owner }},
+	"group": {"", "Group", 8, func(e *Entry) string { return e.group }},
+	"ext": {"", "Ext", 6, func(e *Entry) string {
+		if e.isDir() {
+			return ""
+		}
+		return strings.TrimPrefix(filepath.Ext(e.name), ".")
+	}},
+	"count": {"", "Items", 6, func(e *Entry) string {
+		switch {
+		case !e.isDir():
+			return ""
+		case !e.counted:
+			return "…"
+		}
+		return fmt.Sprint(e.count)
+	}},
+}
+
+func formatTime(zero bool, s string) string {
+	if zero {
+		return "-"
+	}
+	return s
+}
+
+// layoutColumns оставляет из names те колонки, что помещаются в width рядом
+// с именем не короче minNameWidth; лишние отбрасываются с конца списка
+func layoutColumns(names []string, width int) (int, []column) {
+	var cols []column
+	for _, n := range names {
+		if c, ok := columnDefs[n]; ok {
+			c.key = n
+			cols = append(cols, c)
+		}
+	}
+	for len(cols) > 0 {
+		used := 0
+		for _, c := range cols {
+			used += c.width + 1
+		}
+		if width-used >= minNameWidth {
+			return width - used, cols
+		}
+		cols = cols[:len(cols)-1]
+	}
+	return width, nil
+}
+
+func fitText(text string, width int, right bool) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		if width <= 0 {
+			return ""
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	pad := strings.Repeat(" ", width-len(runes))
+	if right {
+		return pad + text
+	}
+	return text + pad
+}
+
+// detailedLine собирает строку подробного вида: имя и колонки выбранной ширины
+func detailedLine(e *Entry, display string, nameW int, cols []column) string {
+	var b strings.Builder
+	b.WriteString(fitText(display, nameW, false))
+	for _, c := range cols {
+		b.WriteByte(' ')
+		b.WriteString(fitText(c.value(e), c.width, true))
+	}
+	return b.String()
+}
+
+func detailedHeader(nameW int, cols []column) string {
+	var b strings.Builder
+	b.WriteString(fitText("Name", nameW, false))
+	for _, c := range cols {
+		b.WriteByte(' ')
+		b.WriteString(fitText(c.title, c.width, true))
+	}
+	return b.String()
+}
+
+// wantsCount сообщает, нужно ли считать элементы всех видимых подкаталогов
+func wantsCount(p *Panel) bool {
+	if !p.detailed {
+		return false
+	}
+	_, cols := layoutColumns(config.Columns, p.w-2)
+	for _, c := range cols {
+		if c.key == "count" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ---------------- config ----------------
+type Config struct {
+	// Columns — колонки подробного вида по порядку; при нехватке ширины
+	// отбрасываются последние. Доступны: size, mtime, ctime, perms, owner,
+	// group, ext, count.
+	Columns []string `json:"columns"`
+	// DetailedView включает подробный вид для панелей при запуске
+	DetailedView bool `json:"detailedView"`
+	// Collation — правила сравнения имён: unicode, natural или bytes
+	Collation string `json:"collation"`
+	// Locale — язык для unicode-сравнения, например "ru"; пусто — из $LANG
+	Locale string `json:"locale"`
+	// Layout — "sidebar" (закладки и список), "dual" (два списка файлов) или
+	// "miller" (родительский каталог, текущий и просмотр)
+	Layout string `json:"layout"`
+	// MillerRatios — относительные ширины трёх колонок раскладки miller
+	MillerRatios []int `json:"millerRatios"`
+	// HideSidebar скрывает закладки в раскладках sidebar и miller
+	HideSidebar bool `json:"hideSidebar"`
+	// Preview делит место списка с просмотром в раскладке sidebar
+	// (в miller просмотр есть всегда)
+	Preview bool `json:"preview"`
+	// PreviewWrap переносит длинные строки просмотра, иначе они обрезаются
+	PreviewWrap bool `json:"previewWrap"`
+	// LineNumbers показывает номера строк в просмотре
+	LineNumbers bool `json:"lineNumbers"`
+	// TabWidth — ширина табуляции в просмотре
+	TabWidth int `json:"tabWidth"`
+	// ImageProtocol — вывод картинок: auto, halfblocks, kitty или sixel
+	ImageProtocol string `json:"imageProtocol"`
+}
+
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		Columns:       []string{"size", "mtime", "perms", "owner", "count"},
+		Collation:     "unicode",
+		Layout:        "sidebar",
+		MillerRatios:  []int{1, 3, 4},
+		LineNumbers:   true,
+		TabWidth:      4,
+		ImageProtocol: "auto",
+	}
+}
+
+func configFile() string {
+	return filepath.Join(homeDir, ".myfm_config.json")
+}
+
+func saveConfig(cfg Config) {
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	_ = os.WriteFile(configFile(), data, 0644)
+}
+
+// loadConfig читает настройки; отсутствующие в файле поля остаются по умолчанию
+func loadConfig() Config {
+	cfg := defaultConfig()
+	data, err := os.ReadFile(configFile())
+	if err != nil {
+		saveConfig(cfg)
+		return cfg
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return defaultConfig()
+	}
+	// без этой проверки nameCollator остался бы пустым при неизвестном режиме
+	if !slices.Contains(collationModes, cfg.Collation) {
+		configWarning = fmt.Sprintf("Unknown collation %q, using unicode", cfg.Collation)
+		cfg.Collation = "unicode"
+	}
+	return cfg
+}
+
+// configWarning показывается при старте, если в настройках нашлись неверные значения
+var configWarning string
+
+var layouts = []string{"sidebar", "dual", "miller"}
+
+// millerWidths делит ширину w между колонками miller по config.MillerRatios;
+// неверные значения заменяются стандартными
+func millerWidths(w int) (int, int, int) {
+	r := config.MillerRatios
+	if len(r) != 3 || r[0] < 0 || r[1] <= 0 || r[2] < 0 {
+		r = defaultConfig().MillerRatios
+	}
+	sum := r[0] + r[1] + r[2]
+	a := w * r[0] / sum
+	b := w * r[1] / sum
+	return a, b, w - a - b
+}
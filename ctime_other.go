@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// fileCtime без Stat_t.Ctim подменяется временем изменения содержимого
+func fileCtime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,689 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- diff ----------------
+// Сравнение двух текстовых файлов построчно алгоритмом Майерса (вариант
+// с линейной памятью: делим задачу по «средней змее»). Изменённые пары
+// строк дополнительно сравниваются по словам, чтобы подсветить, что именно
+// поменялось. Показывается рядом или единым диффом; блок изменений можно
+// перенести из одного файла в другой и сохранить.
+const (
+	diffMaxBytes    = 16 << 20 // файлы больше не сравниваем
+	diffContext     = 3        // строк без изменений вокруг блока
+	diffMaxLineDiff = 1000     // строки длиннее по словам не сравниваем
+)
+
+// diffSeq сравнивает последовательности чисел; changed отмечает удалённые
+// из a и добавленные в b элементы
+type diffSeq struct {
+	a, b   []int
+	ca, cb []bool
+}
+
+func diffMarks(a, b []int) ([]bool, []bool) {
+	d := &diffSeq{a: a, b: b, ca: make([]bool, len(a)), cb: make([]bool, len(b))}
+	d.compare(0, len(a), 0, len(b))
+	return d.ca, d.cb
+}
+
+func (d *diffSeq) compare(x0, x1, y0, y1 int) {
+	// общие начало и конец не сравниваем
+	for x0 < x1 && y0 < y1 && d.a[x0] == d.b[y0] {
+		x0++
+		y0++
+	}
+	for x0 < x1 && y0 < y1 && d.a[x1-1] == d.b[y1-1] {
+		x1--
+		y1--
+	}
+	switch {
+	case x0 == x1:
+		for y := y0; y < y1; y++ {
+			d.cb[y] = true
+		}
+	case y0 == y1:
+		for x := x0; x < x1; x++ {
+			d.ca[x] = true
+		}
+	default:
+		x, y, ok := d.middle(x0, x1, y0, y1)
+		if !ok || (x == x0 && y == y0) || (x == x1 && y == y1) {
+			// общего нет: всё удалено и всё добавлено
+			for i := x0; i < x1; i++ {
+				d.ca[i] = true
+			}
+			for i := y0; i < y1; i++ {
+				d.cb[i] = true
+			}
+			return
+		}
+		d.compare(x0, x, y0, y)
+		d.compare(x, x1, y, y1)
+	}
+}
+
+// middle ищет точку на кратчайшем пути правок, идя одновременно от начала
+// и от конца, пока пути не встретятся
+func (d *diffSeq) middle(x0, x1, y0, y1 int) (int, int, bool) {
+	a, b := d.a[x0:x1], d.b[y0:y1]
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	off, size := maxD, 2*maxD+2
+	vf, vb := make([]int, size), make([]int, size)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[off+1], vb[off+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0
+	var k1start, k1end, k2start, k2end int
+	for step := 0; step < maxD; step++ {
+		// вперёд от начала
+		for k := -step + k1start; k <= step-k1end; k += 2 {
+			i := off + k
+			var x int
+			if k == -step || (k != step && vf[i-1] < vf[i+1]) {
+				x = vf[i+1]
+			} else {
+				x = vf[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[i] = x
+			switch {
+			case x > n:
+				k1end += 2
+			case y > m:
+				k1start += 2
+			case front:
+				j := off + delta - k
+				if j >= 0 && j < size && vb[j] != -1 && x >= n-vb[j] {
+					return x0 + x, y0 + y, true
+				}
+			}
+		}
+		// назад от конца
+		for k := -step + k2start; k <= step-k2end; k += 2 {
+			i := off + k
+			var x int
+			if k == -step || (k != step && vb[i-1] < vb[i+1]) {
+				x = vb[i+1]
+			} else {
+				x = vb[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[i] = x
+			switch {
+			case x > n:
+				k2end += 2
+			case y > m:
+				k2start += 2
+			case !front:
+				j := off + delta - k
+				if j >= 0 && j < size && vf[j] != -1 {
+					fx := vf[j]
+					if fx >= n-x {
+						return x0 + fx, y0 + fx - (j - off), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// diffBlock — подряд идущие изменения: строки [a0, a1) заменены на [b0, b1)
+type diffBlock struct {
+	a0, a1, b0, b1 int
+}
+
+// diffBlocks сравнивает строки и собирает изменения в блоки
+func diffBlocks(a, b []string) []diffBlock {
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	ca, cb := diffMarks(intern(a), intern(b))
+	var blocks []diffBlock
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if (i < len(a) && ca[i]) || (j < len(b) && cb[j]) {
+			blk := diffBlock{a0: i, b0: j}
+			for i < len(a) && ca[i] {
+				i++
+			}
+			for j < len(b) && cb[j] {
+				j++
+			}
+			blk.a1, blk.b1 = i, j
+			blocks = append(blocks, blk)
+			continue
+		}
+		i++
+		j++
+	}
+	return blocks
+}
+
+// wordMarks сравнивает две строки по словам и отмечает руны, которые
+// различаются
+func wordMarks(a, b string) ([]bool, []bool) {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > diffMaxLineDiff || len(rb) > diffMaxLineDiff {
+		return nil, nil
+	}
+	ids := map[string]int{}
+	split := func(r []rune) ([]int, []int) {
+		var toks, starts []int
+		for i := 0; i < len(r); {
+			j := i + 1
+			switch {
+			case isWordRune(r[i]):
+				for j < len(r) && isWordRune(r[j]) {
+					j++
+				}
+			case unicode.IsSpace(r[i]):
+				for j < len(r) && unicode.IsSpace(r[j]) {
+					j++
+				}
+			}
+			t := string(r[i:j])
+			id, ok := ids[t]
+			if !ok {
+				id = len(ids)
+				ids[t] = id
+			}
+			toks, starts = append(toks, id), append(starts, i)
+			i = j
+		}
+		return toks, append(starts, len(r))
+	}
+	ta, sa := split(ra)
+	tb, sb := split(rb)
+	ca, cb := diffMarks(ta, tb)
+	mark := func(changed []bool, starts []int, n int) []bool {
+		m := make([]bool, n)
+		for t, c := range changed {
+			for k := starts[t]; c && k < starts[t+1]; k++ {
+				m[k] = true
+			}
+		}
+		return m
+	}
+	return mark(ca, sa, len(ra)), mark(cb, sb, len(rb))
+}
+
+type diffFile struct {
+	path     string
+	lines    []string // строки без перевода строки
+	final    bool     // файл кончается переводом строки
+	modified bool
+}
+
+func readDiffFile(path string) (*diffFile, error) {
+	if isRemote(path) {
+		return nil, fmt.Errorf("diff works on local files only")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", baseName(path))
+	}
+	if info.Size() > diffMaxBytes {
+		return nil, fmt.Errorf("%s is too large to compare", baseName(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), previewMaxBytes)], 0) >= 0 {
+		return nil, fmt.Errorf("%s is not a text file", baseName(path))
+	}
+	f := &diffFile{path: path}
+	text := string(data)
+	f.final = strings.HasSuffix(text, "\n")
+	if len(data) > 0 {
+		f.lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+	return f, nil
+}
+
+func (f *diffFile) save() error {
+	text := strings.Join(f.lines, "\n")
+	if f.final && len(f.lines) > 0 {
+		text += "\n"
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(f.path, []byte(text), mode); err != nil {
+		return err
+	}
+	f.modified = false
+	return nil
+}
+
+const (
+	rowSame = iota
+	rowChange
+	rowFold
+)
+
+// diffRow — строка экрана: пара строк файлов (-1 — строки нет с этой
+// стороны) или свёрнутый промежуток без изменений
+type diffRow struct {
+	kind         int
+	a, b         int
+	aMark, bMark []bool // изменившиеся руны
+	block        int    // номер блока изменений, -1 — не изменение
+	text         string // у свёрнутого промежутка
+}
+
+type diffView struct {
+	left, right *diffFile
+	blocks      []diffBlock
+	rows        []diffRow
+	blockRow    []int // первая строка экрана каждого блока
+
+	unified    bool
+	block      int // текущий блок изменений
+	top, shift int // прокрутка вниз и вбок
+	tabWidth   int
+	discard    bool   // следующий q закроет без сохранения
+	msg        string // сообщение в нижней строке до следующей клавиши
+	w, h       int
+}
+
+func openDiff(leftPath, rightPath string, tabWidth int) (*diffView, error) {
+	l, err := readDiffFile(leftPath)
+	if err != nil {
+		return nil, err
+	}
+	r, err := readDiffFile(rightPath)
+	if err != nil {
+		return nil, err
+	}
+	d := &diffView{left: l, right: r, tabWidth: max(tabWidth, 1)}
+	d.refresh()
+	if len(d.blocks) == 0 {
+		d.msg = "Files are identical"
+	}
+	return d, nil
+}
+
+// refresh пересчитывает дифф после изменения файла
+func (d *diffView) refresh() {
+	d.blocks = diffBlocks(d.left.lines, d.right.lines)
+	d.block = max(0, min(d.block, len(d.blocks)-1))
+	d.layout()
+}
+
+// layout раскладывает изменения в строки экрана: блоки с diffContext
+// строками вокруг, длинные промежутки без изменений сворачиваются
+func (d *diffView) layout() {
+	d.rows, d.blockRow = nil, nil
+	la, lb := d.left.lines, d.right.lines
+	if len(d.blocks) == 0 {
+		for i := range la {
+			d.rows = append(d.rows, diffRow{kind: rowSame, a: i, b: i, block: -1})
+		}
+		return
+	}
+	fold := func(skip, a, b, na, nb int) {
+		text := fmt.Sprintf("··· %d unchanged lines ···", skip)
+		if d.unified && na+nb > 0 {
+			text = fmt.Sprintf("@@ -%d,%d +%d,%d @@", a+1, na, b+1, nb)
+		}
+		d.rows = append(d.rows, diffRow{kind: rowFold, a: -1, b: -1, block: -1, text: text})
+	}
+	same := func(a, b, n int) {
+		for i := 0; i < n; i++ {
+			d.rows = append(d.rows, diffRow{kind: rowSame, a: a + i, b: b + i, block: -1})
+		}
+	}
+	// свёрнутая строка не короче того, что она заменяет: промежуток
+	// в одну строку показываем как есть
+	ea, eb := 0, 0 // до какого места файлы уже показаны
+	for i := 0; i < len(d.blocks); {
+		// блоки с короткими промежутками между ними идут одним куском
+		j := i + 1
+		for j < len(d.blocks) && d.blocks[j].a0-d.blocks[j-1].a1 <= 2*diffContext+1 {
+			j++
+		}
+		first, last := d.blocks[i], d.blocks[j-1]
+		ctx := first.a0 - ea
+		if ctx > diffContext+1 {
+			ctx = diffContext
+		}
+		sa, sb := first.a0-ctx, first.b0-ctx
+		end := last.a1 + diffContext
+		if end+1 >= len(la) {
+			end = len(la)
+		}
+		na := end - sa
+		nb := na - (last.a1 - sa) + (last.b1 - sb)
+		if sa > ea || d.unified {
+			fold(sa-ea, sa, sb, na, nb)
+		}
+		a, b := sa, sb
+		for k := i; k < j; k++ {
+			blk := d.blocks[k]
+			same(a, b, blk.a0-a)
+			d.blockRow = append(d.blockRow, len(d.rows))
+			d.addBlock(k, blk)
+			a, b = blk.a1, blk.b1
+		}
+		n := end - a
+		same(a, b, n)
+		ea, eb = a+n, b+n
+		i = j
+	}
+	if ea < len(la) || eb < len(lb) {
+		fold(len(la)-ea, 0, 0, 0, 0)
+	}
+}
+
+// addBlock добавляет строки блока: рядом — пары удалённой и добавленной
+// строк, в едином виде — сначала удалённые, потом добавленные
+func (d *diffView) addBlock(k int, blk diffBlock) {
+	na, nb := blk.a1-blk.a0, blk.b1-blk.b0
+	var aMarks, bMarks [][]bool
+	for i := 0; i < max(na, nb); i++ {
+		var am, bm []bool
+		if i < na && i < nb {
+			am, bm = wordMarks(d.line(d.left, blk.a0+i), d.line(d.right, blk.b0+i))
+		}
+		aMarks, bMarks = append(aMarks, am), append(bMarks, bm)
+	}
+	if d.unified {
+		for i := 0; i < na; i++ {
+			d.rows = append(d.rows, diffRow{kind: rowChange, a: blk.a0 + i, b: -1, aMark: aMarks[i], block: k})
+		}
+		for i := 0; i < nb; i++ {
+			d.rows = append(d.rows, diffRow{kind: rowChange, a: -1, b: blk.b0 + i, bMark: bMarks[i], block: k})
+		}
+		return
+	}
+	for i := 0; i < max(na, nb); i++ {
+		r := diffRow{kind: rowChange, a: -1, b: -1, aMark: aMarks[i], bMark: bMarks[i], block: k}
+		if i < na {
+			r.a = blk.a0 + i
+		}
+		if i < nb {
+			r.b = blk.b0 + i
+		}
+		d.rows = append(d.rows, r)
+	}
+}
+
+func (d *diffView) line(f *diffFile, i int) string {
+	return displayLine(f.lines[i], d.tabWidth)
+}
+
+func (d *diffView) scrollTo(top int) {
+	d.top = max(0, min(top, len(d.rows)-d.h))
+}
+
+// goBlock делает текущим блок k и показывает его с контекстом сверху
+func (d *diffView) goBlock(k int) {
+	if len(d.blocks) == 0 {
+		d.msg = "No differences"
+		return
+	}
+	if k < 0 || k >= len(d.blocks) {
+		d.msg = "No more changes"
+		return
+	}
+	d.block = k
+	if row := d.blockRow[k]; row < d.top || row >= d.top+d.h {
+		d.scrollTo(row - diffContext - 2)
+	}
+}
+
+// copyBlock переносит текущий блок из одного файла в другой: toRight —
+// слева направо
+func (d *diffView) copyBlock(toRight bool) {
+	if len(d.blocks) == 0 {
+		d.msg = "No differences"
+		return
+	}
+	blk := d.blocks[d.block]
+	src, dst := d.left, d.right
+	s0, s1, t0, t1 := blk.a0, blk.a1, blk.b0, blk.b1
+	if !toRight {
+		src, dst = d.right, d.left
+		s0, s1, t0, t1 = blk.b0, blk.b1, blk.a0, blk.a1
+	}
+	lines := append([]string{}, dst.lines[:t0]...)
+	lines = append(lines, src.lines[s0:s1]...)
+	dst.lines = append(lines, dst.lines[t1:]...)
+	dst.modified = true
+	top := d.top
+	d.refresh()
+	d.scrollTo(top)
+	d.msg = fmt.Sprintf("Copied to %s (s to save)", baseName(dst.path))
+}
+
+func (d *diffView) saveAll() {
+	var saved []string
+	for _, f := range []*diffFile{d.left, d.right} {
+		if !f.modified {
+			continue
+		}
+		if err := f.save(); err != nil {
+			d.msg = fmt.Sprintf("Save error: %v", err)
+			return
+		}
+		saved = append(saved, baseName(f.path))
+	}
+	if len(saved) == 0 {
+		d.msg = "Nothing to save"
+		return
+	}
+	d.msg = "Saved " + strings.Join(saved, ", ")
+}
+
+// diffKey обрабатывает клавишу; false — сравнение закрыто
+func diffKey(d *diffView, ev *tcell.EventKey) bool {
+	d.msg = ""
+	quit := ev.Key() == tcell.KeyEscape || (ev.Key() == tcell.KeyRune && ev.Rune() == 'q')
+	if quit {
+		if (d.left.modified || d.right.modified) && !d.discard {
+			d.discard = true
+			d.msg = "Unsaved changes: s to save, q again to discard"
+			return true
+		}
+		return false
+	}
+	d.discard = false
+	page := max(1, d.h-1)
+	switch ev.Key() {
+	case tcell.KeyUp:
+		d.scrollTo(d.top - 1)
+	case tcell.KeyDown:
+		d.scrollTo(d.top + 1)
+	case tcell.KeyPgUp:
+		d.scrollTo(d.top - page)
+	case tcell.KeyPgDn:
+		d.scrollTo(d.top + page)
+	case tcell.KeyHome:
+		d.top = 0
+	case tcell.KeyEnd:
+		d.scrollTo(len(d.rows))
+	case tcell.KeyLeft:
+		d.shift = max(0, d.shift-8)
+	case tcell.KeyRight:
+		d.shift += 8
+	case tcell.KeyTAB:
+		d.toggleUnified()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'k':
+			d.scrollTo(d.top - 1)
+		case 'j':
+			d.scrollTo(d.top + 1)
+		case ' ':
+			d.scrollTo(d.top + page)
+		case 'b':
+			d.scrollTo(d.top - page)
+		case 'n', ']':
+			d.goBlock(d.block + 1)
+		case 'N', '[':
+			d.goBlock(d.block - 1)
+		case 'u':
+			d.toggleUnified()
+		case '>':
+			d.copyBlock(true)
+		case '<':
+			d.copyBlock(false)
+		case 's':
+			d.saveAll()
+		}
+	}
+	return true
+}
+
+func (d *diffView) toggleUnified() {
+	d.unified = !d.unified
+	d.layout()
+	if len(d.blockRow) > 0 {
+		d.top = 0
+		d.scrollTo(d.blockRow[d.block] - diffContext - 2)
+	}
+}
+
+// drawDiff рисует сравнение на весь экран: заголовок с именами файлов,
+// строки и строку подсказок или сообщения
+func drawDiff(s tcell.Screen, d *diffView) {
+	sw, sh := s.Size()
+	s.Fill(' ', tcell.StyleDefault)
+	d.w, d.h = sw, max(1, sh-2)
+	d.scrollTo(d.top)
+
+	barStyle := tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGray)
+	grayStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	curStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	delStyle := tcell.StyleDefault.Foreground(tcell.ColorRed)
+	addStyle := tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	delMark := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorMaroon)
+	addMark := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorDarkGreen)
+
+	name := func(f *diffFile) string {
+		n := displayPath(f.path)
+		if f.modified {
+			n += " [modified]"
+		}
+		return n
+	}
+	info := fmt.Sprintf(" %d changes ", len(d.blocks))
+	if len(d.blocks) > 0 {
+		info = fmt.Sprintf(" change %d/%d ", d.block+1, len(d.blocks))
+	}
+	drawText(s, 0, 0, fmt.Sprintf(" %s ⟷ %s", name(d.left), name(d.right)), barStyle, sw)
+	drawText(s, sw-len([]rune(info)), 0, info, barStyle, len([]rune(info)))
+
+	lines := max(len(d.left.lines), len(d.right.lines), 1)
+	numW := len(fmt.Sprint(lines))
+
+	// text выводит строку со сдвигом d.shift, изменившиеся руны — стилем mark
+	text := func(x, y, w int, line string, marks []bool, style, mark tcell.Style) {
+		r := []rune(line)
+		for i := 0; i < w && d.shift+i < len(r); i++ {
+			st := style
+			if k := d.shift + i; k < len(marks) && marks[k] {
+				st = mark
+			}
+			s.SetContent(x+i, y, r[d.shift+i], nil, st)
+		}
+	}
+	num := func(x, y, n int) {
+		if n >= 0 {
+			drawText(s, x, y, fmt.Sprintf("%*d", numW, n+1), grayStyle, numW)
+		}
+	}
+
+	half := (sw - 1) / 2
+	for row := 0; row < d.h && d.top+row < len(d.rows); row++ {
+		r := &d.rows[d.top+row]
+		y := 1 + row
+		current := r.block >= 0 && r.block == d.block
+		if r.kind == rowFold {
+			drawText(s, 0, y, " "+r.text, grayStyle, sw)
+			continue
+		}
+		if d.unified {
+			num(0, y, r.a)
+			num(numW+1, y, r.b)
+			x := 2*numW + 2
+			sign, line, marks, style, mark := ' ', "", []bool(nil), textStyle, textStyle
+			switch {
+			case r.kind == rowSame:
+				line = d.line(d.left, r.a)
+			case r.a >= 0:
+				sign, line, marks, style, mark = '-', d.line(d.left, r.a), r.aMark, delStyle, delMark
+			default:
+				sign, line, marks, style, mark = '+', d.line(d.right, r.b), r.bMark, addStyle, addMark
+			}
+			if current {
+				s.SetContent(x, y, '▌', nil, curStyle)
+			}
+			s.SetContent(x+1, y, sign, nil, style)
+			text(x+3, y, sw-x-3, line, marks, style, mark)
+			continue
+		}
+
+		// рядом: левый файл, разделитель, правый
+		sep, sepStyle := '│', grayStyle
+		if current {
+			sep, sepStyle = '┃', curStyle
+		}
+		s.SetContent(half, y, sep, nil, sepStyle)
+		side := func(x, w int, f *diffFile, n int, marks []bool, style, mark tcell.Style) {
+			if n < 0 {
+				return
+			}
+			num(x, y, n)
+			if r.kind == rowSame {
+				style, mark = textStyle, textStyle
+			}
+			text(x+numW+1, y, w-numW-1, d.line(f, n), marks, style, mark)
+		}
+		side(0, half, d.left, r.a, r.aMark, delStyle, delMark)
+		side(half+1, sw-half-1, d.right, r.b, r.bMark, addStyle, addMark)
+	}
+
+	bottom := d.msg
+	if bottom == "" {
+		bottom = "n N next/prev change  > < copy change right/left  s save  u unified  q quit"
+	}
+	drawText(s, 0, sh-1, " "+bottom, grayStyle, sw)
+}
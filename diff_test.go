@@ -0,0 +1,178 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lcsLen — длина наибольшей общей подпоследовательности простым ДП
+func lcsLen(a, b []int) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// kept — элементы, не отмеченные как изменённые
+func kept(s []int, changed []bool) []int {
+	var res []int
+	for i, c := range changed {
+		if !c {
+			res = append(res, s[i])
+		}
+	}
+	return res
+}
+
+// checkMarks проверяет, что неизменённые элементы — общая подпоследовательность
+// наибольшей длины, то есть правок минимум
+func checkMarks(t *testing.T, a, b []int) bool {
+	t.Helper()
+	ca, cb := diffMarks(a, b)
+	if len(ca) != len(a) || len(cb) != len(b) {
+		t.Errorf("diffMarks(%v, %v): %d/%d marks", a, b, len(ca), len(cb))
+		return false
+	}
+	ka, kb := kept(a, ca), kept(b, cb)
+	if !slices.Equal(ka, kb) {
+		t.Errorf("diffMarks(%v, %v): kept %v and %v differ", a, b, ka, kb)
+		return false
+	}
+	if want := lcsLen(a, b); len(ka) != want {
+		t.Errorf("diffMarks(%v, %v): kept %d elements, LCS is %d", a, b, len(ka), want)
+		return false
+	}
+	return true
+}
+
+func TestDiffMarks(t *testing.T) {
+	tests := [][2][]int{
+		{nil, nil},
+		{{1, 2, 3}, nil},
+		{nil, {1, 2, 3}},
+		{{1, 2, 3}, {1, 2, 3}},
+		{{1, 2, 3}, {4, 5, 6}},
+		{{1, 2, 3, 4}, {1, 3, 4}},
+		{{1, 3, 4}, {1, 2, 3, 4}},
+		{{1, 2, 3, 1, 2, 2, 1}, {3, 2, 1, 2, 1, 3}}, // пример из статьи Майерса
+		{{1, 1, 1, 1}, {1, 1}},
+		{{1, 2, 1, 2, 1, 2}, {2, 1, 2, 1, 2, 1}},
+	}
+	for _, tt := range tests {
+		checkMarks(t, tt[0], tt[1])
+	}
+}
+
+func TestDiffMarksRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	gen := func(n, alphabet int) []int {
+		s := make([]int, n)
+		for i := range s {
+			s[i] = rng.Intn(alphabet)
+		}
+		return s
+	}
+	for i := 0; i < 5000; i++ {
+		alphabet := 1 + rng.Intn(5)
+		a := gen(rng.Intn(40), alphabet)
+		var b []int
+		if rng.Intn(2) == 0 {
+			b = gen(rng.Intn(40), alphabet)
+		} else {
+			// похожая последовательность: несколько правок a
+			b = slices.Clone(a)
+			for e := rng.Intn(6); e > 0; e-- {
+				k := rng.Intn(len(b) + 1)
+				if rng.Intn(2) == 0 && k < len(b) {
+					b = slices.Delete(b, k, k+1)
+				} else {
+					b = slices.Insert(b, k, rng.Intn(alphabet))
+				}
+			}
+		}
+		if !checkMarks(t, a, b) {
+			return
+		}
+	}
+}
+
+func TestDiffBlocks(t *testing.T) {
+	tests := []struct {
+		a, b string // строки через '|'
+		want []diffBlock
+	}{
+		{"a|b|c", "a|b|c", nil},
+		{"a|b|c", "a|x|c", []diffBlock{{1, 2, 1, 2}}},
+		{"a|b|c", "a|c", []diffBlock{{1, 2, 1, 1}}},
+		{"a|c", "a|b|c", []diffBlock{{1, 1, 1, 2}}},
+		{"a|b|c|d|e", "x|b|c|d|y", []diffBlock{{0, 1, 0, 1}, {4, 5, 4, 5}}},
+		{"a|b", "c|d|e", []diffBlock{{0, 2, 0, 3}}},
+		{"", "", nil},
+		{"a|b|c", "", []diffBlock{{0, 3, 0, 0}}},
+	}
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, "|")
+	}
+	for _, tt := range tests {
+		if got := diffBlocks(split(tt.a), split(tt.b)); !slices.Equal(got, tt.want) {
+			t.Errorf("diffBlocks(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// markString показывает отмеченные руны как '^', остальные как пробел
+func markString(m []bool) string {
+	var b strings.Builder
+	for _, c := range m {
+		if c {
+			b.WriteByte('^')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func TestWordMarks(t *testing.T) {
+	tests := []struct {
+		a, b         string
+		wantA, wantB string
+	}{
+		{"same line", "same line", "", ""},
+		{"return x + 1", "return y + 1", "       ^", "       ^"},
+		{"foo(bar)", "foo(baz)", "    ^^^", "    ^^^"},
+		{"a b", "a  b", " ^", " ^^"},
+		{"старое значение", "новое значение", "^^^^^^", "^^^^^"},
+		{"x", "", "^", ""},
+		{"", "y = 2", "", "^^^^^"},
+	}
+	for _, tt := range tests {
+		ma, mb := wordMarks(tt.a, tt.b)
+		if len(ma) != len([]rune(tt.a)) || len(mb) != len([]rune(tt.b)) {
+			t.Errorf("wordMarks(%q, %q): %d/%d marks", tt.a, tt.b, len(ma), len(mb))
+			continue
+		}
+		if ga, gb := markString(ma), markString(mb); ga != tt.wantA || gb != tt.wantB {
+			t.Errorf("wordMarks(%q, %q) = %q, %q; want %q, %q", tt.a, tt.b, ga, gb, tt.wantA, tt.wantB)
+		}
+	}
+
+	// слишком длинные строки по словам не сравниваются
+	long := strings.Repeat("w ", diffMaxLineDiff)
+	if ma, mb := wordMarks(long, long+"x"); ma != nil || mb != nil {
+		t.Errorf("wordMarks on long lines = %v, %v; want nil", ma != nil, mb != nil)
+	}
+}
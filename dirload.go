@@ -0,0 +1,285 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	loadBatchSize = 1024                   // записей за один вызов ReadDir
+	loadFlushTime = 100 * time.Millisecond // как часто отдавать накопленное в интерфейс
+	loadTimeout   = 10 * time.Second       // без новых записей дольше — считаем, что каталог завис
+)
+
+// dirLoad — текущее фоновое чтение каталога в панель
+type dirLoad struct {
+	path       string
+	query      *findQuery // поиск по атрибутам вместо чтения каталога
+	gen        int
+	selectName string
+	entries    []Entry
+	switched   bool   // панель уже показывает path
+	inPlace    bool   // перечитывание показанного каталога: список меняется по завершении
+	histPos    int    // переход по истории: индекс записи, иначе -1
+	offset     int    // прокрутка, которую надо восстановить
+	prev       *Entry // при перечитывании — бывший элемент под курсором
+	progress   time.Time
+	cancel     chan struct{}
+}
+
+// dirLoadEvent несёт очередную порцию записей каталога через общий канал событий
+type dirLoadEvent struct {
+	when    time.Time
+	panel   *Panel
+	gen     int
+	entries []Entry
+	done    bool
+	err     error
+}
+
+func (e *dirLoadEvent) When() time.Time { return e.when }
+
+// startLoad начинает читать path в фоне, отменяя предыдущее чтение панели.
+// До прихода первой порции панель показывает прежний каталог, так что ошибка
+// открытия оставляет пользователя на месте. selectName — элемент, на который
+// поставить курсор после загрузки.
+func startLoad(p *Panel, path, selectName string, out chan<- tcell.Event) {
+	startQuery(p, path, nil, selectName, out)
+}
+
+// startQuery загружает в панель результаты поиска q под path (при q == nil —
+// сам каталог)
+func startQuery(p *Panel, path string, q *findQuery, selectName string, out chan<- tcell.Event) {
+	cancelLoad(p)
+	p.loadGen++
+	ld := &dirLoad{
+		path:       path,
+		query:      q,
+		gen:        p.loadGen,
+		selectName: selectName,
+		histPos:    -1,
+		progress:   time.Now(),
+		cancel:     make(chan struct{}),
+	}
+	p.loading = ld
+	// ключи сравнения имён считаем в фоне отдельным сортировщиком
+	var keyer *entryKeyer
+	if nameCollator != nil {
+		keyer = newEntryKeyer(newNameCollator(), collationGen)
+	}
+	if q != nil {
+		go findAsync(p, ld.gen, path, q, showHidden, keyer, ld.cancel, out)
+		return
+	}
+	go readDirAsync(p, ld.gen, path, keyer, ld.cancel, out)
+}
+
+// reloadPanel перечитывает каталог панели, оставляя курсор на том же элементе,
+// а если он исчез — на его ближайшем соседе
+func reloadPanel(p *Panel, out chan<- tcell.Event) {
+	var prev *Entry
+	if e := selectedEntry(p); e != nil {
+		copied := *e
+		prev = &copied
+	}
+	name := ""
+	if prev != nil {
+		name = prev.name
+	}
+	startQuery(p, p.path, p.query, name, out)
+	p.loading.prev = prev
+	if p.tree != nil {
+		// узел внутри дерева найдётся, когда перечитаются раскрытые каталоги
+		p.tree.want = name
+	}
+}
+
+// cancelLoad прерывает чтение; уже показанная часть каталога остаётся и сортируется
+func cancelLoad(p *Panel) {
+	ld := p.loading
+	if ld == nil {
+		return
+	}
+	close(ld.cancel)
+	if ld.switched {
+		finishLoad(p, ld, "")
+	}
+	p.loading = nil
+}
+
+// finishLoad сортирует прочитанное; если пользователь успел сдвинуть курсор,
+// он остаётся на том же имени
+func finishLoad(p *Panel, ld *dirLoad, name string) {
+	if name == "" && p.cursor > 0 && p.cursor < len(p.items) {
+		name = p.items[p.cursor].name
+	}
+	p.sort = sortFor(p.path)
+	setEntries(p, arrangeEntries(ld.entries, p.sort))
+	p.loading = nil
+	if selectName(p, name) {
+		return
+	}
+	if ld.prev != nil {
+		// исчезнувший элемент: встаём на место, которое он занимал бы в новом списке
+		p.cursor = sort.Search(len(p.items), func(i int) bool {
+			return !lessEntry(&p.items[i], ld.prev, p.sort)
+		})
+	}
+	ensureCursorBounds(p)
+}
+
+func readDirAsync(p *Panel, gen int, path string, keyer *entryKeyer, cancel <-chan struct{}, out chan<- tcell.Event) {
+	send := func(entries []Entry, done bool, err error) bool {
+		ev := &dirLoadEvent{when: time.Now(), panel: p, gen: gen, entries: entries, done: done, err: err}
+		select {
+		case out <- ev:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	if isRemote(path) {
+		des, err := davReadDir(davCtx, path)
+		entries := make([]Entry, 0, len(des))
+		for _, de := range des {
+			e := newEntry(path, de)
+			keyer.fill(&e)
+			entries = append(entries, e)
+		}
+		send(entries, true, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		send(nil, true, err)
+		return
+	}
+	defer f.Close()
+
+	var batch []Entry
+	last := time.Now()
+	for {
+		select {
+		case <-cancel:
+			return
+		default:
+		}
+		chunk, err := f.ReadDir(loadBatchSize)
+		for _, de := range chunk {
+			e := newEntry(path, de)
+			keyer.fill(&e)
+			batch = append(batch, e)
+		}
+		if err == io.EOF {
+			send(batch, true, nil)
+			return
+		}
+		if err != nil {
+			send(batch, true, err)
+			return
+		}
+		if time.Since(last) >= loadFlushTime {
+			if !send(batch, false, nil) {
+				return
+			}
+			batch = nil
+			last = time.Now()
+		}
+	}
+}
+
+// applyDirLoad переносит порцию в панель. Пока чтение идёт, записи добавляются
+// в конец как есть; по завершении список сортируется целиком. Уже показанный
+// каталог при перечитывании не мигает: прежний список остаётся на экране, пока
+// новый не прочитан до конца.
+func applyDirLoad(p *Panel, ev *dirLoadEvent, out chan<- tcell.Event) error {
+	ld := p.loading
+	if ld == nil || ld.gen != ev.gen {
+		// порция от отменённого чтения
+		return nil
+	}
+	ld.progress = time.Now()
+
+	if ev.err != nil && !ld.switched && len(ev.entries) == 0 {
+		p.loading = nil
+		// текущий каталог удалили — поднимаемся к родителю
+		if os.IsNotExist(ev.err) && ld.path == p.path && p.query == nil && !isRootPath(p.path) {
+			startLoad(p, parentPath(p.path), baseName(p.path), out)
+			return nil
+		}
+		return ev.err
+	}
+
+	if !ld.switched && !ld.inPlace && ld.histPos >= 0 && ld.path == p.path {
+		// запись истории с тем же каталогом, например A→B→A: позицию в истории
+		// и прокрутку ставим так же, как при смене каталога
+		p.histPos = ld.histPos
+		p.offset = ld.offset
+	}
+
+	if !ld.switched && (ld.inPlace || ld.path == p.path && ld.query == p.query && p.all != nil) {
+		ld.inPlace = true
+		ld.entries = append(ld.entries, ev.entries...)
+		if ev.err != nil {
+			// недочитанный каталог не показываем вместо целого
+			p.loading = nil
+			return ev.err
+		}
+		if ev.done {
+			// при перечитывании курсор остаётся там, куда его успел поставить пользователь
+			if e := selectedEntry(p); e != nil && ld.prev != nil {
+				copied := *e
+				ld.prev, ld.selectName = &copied, e.name
+				if p.tree != nil {
+					p.tree.want = e.name
+				}
+			}
+			finishLoad(p, ld, ld.selectName)
+		}
+		return nil
+	}
+
+	if !ld.switched {
+		ld.switched = true
+		if ld.path != p.path {
+			if !p.noHistory {
+				rememberPosition(p)
+				if ld.histPos >= 0 {
+					p.histPos = ld.histPos
+				} else {
+					pushHistory(p, ld.path)
+				}
+				recordVisit(ld.path)
+			}
+			switchFilter(p, ld.path)
+			p.path = ld.path
+			p.cursor = 0
+			p.offset = ld.offset
+		} else if ld.query != p.query {
+			// переход между каталогом и результатами поиска в нём
+			p.cursor = 0
+			p.offset = 0
+		}
+		p.query = ld.query
+		p.all = nil
+		p.items = nil
+	}
+	ld.entries = append(ld.entries, ev.entries...)
+
+	if !ev.done {
+		visible := visibleEntries(ev.entries)
+		p.all = append(p.all, visible...)
+		p.items = append(p.items, filterEntries(visible, p.filter)...)
+		ensureCursorBounds(p)
+		return nil
+	}
+
+	finishLoad(p, ld, ld.selectName)
+	return ev.err
+}
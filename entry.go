@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type entryKind int
+
+const (
+	kindFile entryKind = iota
+	kindDir
+	kindOther // устройства, сокеты, FIFO и битые ссылки
+)
+
+// Entry — элемент списка. Метаданные собираются один раз при чтении каталога,
+// поэтому отрисовка, сортировка и строка статуса не обращаются к диску.
+type Entry struct {
+	name    string
+	kind    entryKind // для ссылок — тип цели
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+	ctime   time.Time
+	owner   string
+	group   string
+	link    string // цель символической ссылки
+	err     error
+
+	// число элементов каталога считается в фоне, см. countDirAsync
+	counted     bool
+	counting    bool
+	count       int
+	hiddenCount int
+
+	// ключ сравнения имени для режима unicode, см. collate.go
+	key    []byte
+	keyGen int
+
+	tree string // направляющие и отметка раскрытия в режиме дерева, см. panelItems
+}
+
+func (e *Entry) isDir() bool {
+	return e.kind == kindDir
+}
+
+func kindOf(mode os.FileMode) entryKind {
+	switch {
+	case mode.IsDir():
+		return kindDir
+	case mode.IsRegular():
+		return kindFile
+	}
+	return kindOther
+}
+
+func fillInfo(e *Entry, info os.FileInfo) {
+	e.mode = info.Mode()
+	e.size = info.Size()
+	e.modTime = info.ModTime()
+	e.ctime = fileCtime(info)
+	e.owner, e.group = fileOwner(info)
+	e.kind = kindOf(e.mode)
+}
+
+// newEntry собирает метаданные записи каталога dir; вызывается из фоновой загрузки
+func newEntry(dir string, de os.DirEntry) Entry {
+	e := Entry{name: de.Name()}
+	info, err := de.Info()
+	if err != nil {
+		e.err = err
+		e.kind = kindOf(de.Type())
+		return e
+	}
+	fillInfo(&e, info)
+
+	if e.mode&os.ModeSymlink != 0 && !isRemote(dir) {
+		followLink(&e, joinPath(dir, e.name))
+	}
+	return e
+}
+
+// followLink дополняет запись ссылки: режим остаётся от самой ссылки, а тип и размер берутся у цели
+func followLink(e *Entry, full string) {
+	e.link, _ = os.Readlink(full)
+	if target, err := os.Stat(full); err == nil {
+		e.kind = kindOf(target.Mode())
+		if !target.IsDir() {
+			e.size = target.Size()
+		}
+	} else {
+		e.err = err
+		e.kind = kindOther
+	}
+}
+
+// statEntry перечитывает одну запись, например когда inotify сообщил об изменении цели
+func statEntry(p string) Entry {
+	e := Entry{name: baseName(p)}
+	var info os.FileInfo
+	var err error
+	if isRemote(p) {
+		info, err = statPath(davCtx, p)
+	} else {
+		info, err = os.Lstat(p)
+	}
+	if err != nil {
+		e.err = err
+		e.kind = kindOther
+		return e
+	}
+	fillInfo(&e, info)
+	if e.mode&os.ModeSymlink != 0 && !isRemote(p) {
+		followLink(&e, p)
+	}
+	return e
+}
+
+// bookmarkEntry описывает закладку; путь хранится в name, "Home" — домашний каталог
+func bookmarkEntry(bookmark string) Entry {
+	e := Entry{name: bookmark, kind: kindDir}
+	if bookmark != "Home" && !isRemote(bookmark) {
+		if info, err := os.Stat(bookmark); err != nil || !info.IsDir() {
+			e.kind = kindOther
+		}
+	}
+	return e
+}
+
+func bookmarkEntries(bookmarks []string) []Entry {
+	res := make([]Entry, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		res = append(res, bookmarkEntry(b))
+	}
+	return res
+}
+
+func entryNames(entries []Entry) []string {
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.name)
+	}
+	return res
+}
+
+// entryPath возвращает полный путь элемента панели
+func entryPath(p *Panel, e *Entry) string {
+	if p.path == "" {
+		if e.name == "Home" {
+			return homeDir
+		}
+		return e.name
+	}
+	return joinPath(p.path, e.name)
+}
+
+// selectedEntry возвращает элемент под курсором или nil для пустой панели
+func selectedEntry(p *Panel) *Entry {
+	if p.cursor < 0 || p.cursor >= len(p.items) {
+		return nil
+	}
+	return &p.items[p.cursor]
+}
+
+// entryInfo формирует строку статуса из сохранённых метаданных
+func entryInfo(e *Entry) string {
+	if e.err != nil && e.mode == 0 {
+		return "error"
+	}
+	mode := e.mode.String()
+	modTime := e.modTime.Format("2006-01-02 15:04")
+	link := ""
+	if e.link != "" {
+		link = " -> " + e.link
+	}
+
+	if e.isDir() {
+		if !e.counted {
+			return fmt.Sprintf("%-12s%6s%6s%10s   %s  %s%s", mode, "…", "…", "-", modTime, e.owner, link)
+		}
+		return fmt.Sprintf("%-12s%6d%6d%10s   %s  %s%s", mode, e.count, e.hiddenCount, "-", modTime, e.owner, link)
+	}
+
+	sizeStr := humanSize(e.size)
+	return fmt.Sprintf("%-12s%6d%6d%10s   %s  %s%s", mode, 0, 0, sizeStr, modTime, e.owner, link)
+}
+
+// ---------------- directory counts ----------------
+// dirCountEvent сообщает число элементов подкаталога, посчитанное в фоне
+type dirCountEvent struct {
+	when   time.Time
+	panel  *Panel
+	dir    string
+	name   string
+	total  int
+	hidden int
+}
+
+func (e *dirCountEvent) When() time.Time { return e.when }
+
+// countDirAsync считает элементы подкаталога name, не блокируя отрисовку
+func countDirAsync(p *Panel, dir, name string, out chan<- tcell.Event) {
+	go func() {
+		ev := &dirCountEvent{when: time.Now(), panel: p, dir: dir, name: name}
+		if !isRemote(dir) {
+			if f, err := os.Open(joinPath(dir, name)); err == nil {
+				names, _ := f.Readdirnames(-1)
+				f.Close()
+				ev.total = len(names)
+				for _, n := range names {
+					if isHiddenName(n) {
+						ev.hidden++
+					}
+				}
+			}
+		}
+		out <- ev
+	}()
+}
+
+// entryLists возвращает списки панели, где может лежать запись name: при
+// активном фильтре или в дереве items — копия, обновлять нужно все
+func entryLists(p *Panel, name string) [][]Entry {
+	lists := [][]Entry{p.items, p.all}
+	if p.tree != nil {
+		lists = append(lists, p.tree.children[filepath.Dir(name)])
+	}
+	return lists
+}
+
+func applyDirCount(ev *dirCountEvent) {
+	p := ev.panel
+	if p.path != ev.dir {
+		return
+	}
+	for _, list := range entryLists(p, ev.name) {
+		for i := range list {
+			if list[i].name == ev.name {
+				list[i].counted = true
+				list[i].counting = false
+				list[i].count = ev.total
+				list[i].hiddenCount = ev.hidden
+				break
+			}
+		}
+	}
+}
+
+// refreshEntry перечитывает метаданные записи e панели во всех её списках
+func refreshEntry(p *Panel, e *Entry) {
+	name := e.name
+	fresh := statEntry(entryPath(p, e))
+	for _, list := range entryLists(p, name) {
+		for i := range list {
+			if list[i].name == name {
+				// в результатах поиска и дереве имя — путь от корня, его сохраняем
+				tree := list[i].tree
+				list[i] = fresh
+				list[i].name, list[i].tree = name, tree
+				break
+			}
+		}
+	}
+}
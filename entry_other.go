@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+func fileOwner(info os.FileInfo) (string, string) {
+	return "", ""
+}
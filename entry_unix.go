@@ -0,0 +1,46 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	ownerMu    sync.Mutex
+	ownerNames = map[uint32]string{}
+	groupNames = map[uint32]string{}
+)
+
+// fileOwner возвращает имена владельца и группы; имена кэшируются,
+// чтобы не читать /etc/passwd на каждый файл
+func fileOwner(info os.FileInfo) (string, string) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	ownerMu.Lock()
+	defer ownerMu.Unlock()
+
+	owner, ok := ownerNames[st.Uid]
+	if !ok {
+		owner = strconv.FormatUint(uint64(st.Uid), 10)
+		if u, err := user.LookupId(owner); err == nil {
+			owner = u.Username
+		}
+		ownerNames[st.Uid] = owner
+	}
+	group, ok := groupNames[st.Gid]
+	if !ok {
+		group = strconv.FormatUint(uint64(st.Gid), 10)
+		if g, err := user.LookupGroupId(group); err == nil {
+			group = g.Name
+		}
+		groupNames[st.Gid] = group
+	}
+	return owner, group
+}
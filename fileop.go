@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- background file operations ----------------
+// Копирование, перенос, удаление и скачивание с сервера могут идти долго,
+// поэтому выполняются в фоне; итог приходит в общий канал событий, а ESC
+// прерывает операцию отменой её контекста.
+
+// fileOp — идущая операция; одновременно выполняется только одна
+type fileOp struct {
+	gen    int
+	text   string          // что делается, для строки статуса
+	done   func(err error) // вызывается в цикле интерфейса по завершении
+	cancel context.CancelFunc
+}
+
+// fileOpEvent сообщает, что операция закончилась
+type fileOpEvent struct {
+	when time.Time
+	gen  int
+	err  error
+}
+
+func (e *fileOpEvent) When() time.Time { return e.when }
+
+var fileOpGen int
+
+// startFileOp запускает work в фоне. Контекст наследуется от davCtx, так что
+// при выходе операция тоже прерывается.
+func startFileOp(text string, work func(ctx context.Context) error, done func(err error), out chan<- tcell.Event) *fileOp {
+	fileOpGen++
+	ctx, cancel := context.WithCancel(davCtx)
+	op := &fileOp{gen: fileOpGen, text: text, done: done, cancel: cancel}
+	go func() {
+		err := work(ctx)
+		cancel()
+		out <- &fileOpEvent{when: time.Now(), gen: op.gen, err: err}
+	}()
+	return op
+}
+
+// downloadDir — личный каталог сеанса для файлов, скачанных с сервера для
+// просмотра. Он создаётся с правами 0700 под случайным именем, так что другой
+// пользователь не подложит в /tmp ссылку на чужой файл вместо скачиваемого.
+var downloadDir string
+
+func downloadPath(name string) (string, error) {
+	if downloadDir == "" {
+		dir, err := os.MkdirTemp("", "go-walker-")
+		if err != nil {
+			return "", err
+		}
+		downloadDir = dir
+	}
+	return filepath.Join(downloadDir, name), nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ---------------- filter ----------------
+var filterModes = []string{"substr", "glob", "regex"}
+
+// listFilter сужает список панели по мере ввода. Все режимы сводятся к
+// регулярному выражению; регистр учитывается, только если в тексте есть
+// заглавные буквы.
+type listFilter struct {
+	text   string
+	mode   string
+	pinned bool // закреплён за каталогом и не сбрасывается при переходе
+	re     *regexp.Regexp
+	err    error
+}
+
+// закреплённые фильтры каталогов, восстанавливаются при возврате в каталог
+var dirFilters = map[string]*listFilter{}
+
+func newFilter() *listFilter {
+	return &listFilter{mode: filterModes[0]}
+}
+
+func (f *listFilter) compile() {
+	f.re, f.err = nil, nil
+	if f.text == "" {
+		return
+	}
+	expr := ""
+	switch f.mode {
+	case "glob":
+		expr = globToRegexp(f.text)
+	case "regex":
+		expr = f.text
+	default:
+		expr = regexp.QuoteMeta(f.text)
+	}
+	if !hasUpper(f.text) {
+		expr = "(?i)" + expr
+	}
+	f.re, f.err = regexp.Compile(expr)
+}
+
+func hasUpper(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegexp строит выражение для всего имени; литеральные куски шаблона
+// становятся группами, чтобы их можно было подсветить
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	literal := ""
+	flush := func() {
+		if literal != "" {
+			b.WriteString("(" + regexp.QuoteMeta(literal) + ")")
+			literal = ""
+		}
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			flush()
+			b.WriteString(".*")
+		case '?':
+			flush()
+			b.WriteString(".")
+		case '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				literal += "["
+				continue
+			}
+			flush()
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("([" + class + "])")
+			i += j
+		default:
+			literal += string(c)
+		}
+	}
+	flush()
+	b.WriteString("$")
+	return b.String()
+}
+
+// active сообщает, что фильтр что-то отсеивает
+func (f *listFilter) active() bool {
+	return f != nil && f.re != nil
+}
+
+func (f *listFilter) match(name string) bool {
+	return !f.active() || f.re.MatchString(name)
+}
+
+// spans возвращает байтовые отрезки имени, которые надо подсветить
+func (f *listFilter) spans(name string) [][2]int {
+	if !f.active() {
+		return nil
+	}
+	var res [][2]int
+	for _, m := range f.re.FindAllStringSubmatchIndex(name, -1) {
+		if len(m) == 2 {
+			res = append(res, [2]int{m[0], m[1]})
+			continue
+		}
+		// у шаблона подсвечиваем только группы — литеральные куски
+		for g := 2; g+1 < len(m); g += 2 {
+			if m[g] >= 0 && m[g+1] > m[g] {
+				res = append(res, [2]int{m[g], m[g+1]})
+			}
+		}
+	}
+	return res
+}
+
+// highlightRunes переводит байтовые отрезки в набор индексов рун
+func highlightRunes(name string, spans [][2]int) map[int]bool {
+	if len(spans) == 0 {
+		return nil
+	}
+	res := map[int]bool{}
+	ri := 0
+	for bi := range name {
+		for _, sp := range spans {
+			if bi >= sp[0] && bi < sp[1] {
+				res[ri] = true
+				break
+			}
+		}
+		ri++
+	}
+	return res
+}
+
+func (f *listFilter) String() string {
+	pin := ""
+	if f.pinned {
+		pin = " pinned"
+	}
+	bad := ""
+	if f.err != nil {
+		bad = " invalid"
+	}
+	return fmt.Sprintf("/%s [%s%s%s]", f.text, f.mode, pin, bad)
+}
+
+func filterEntries(entries []Entry, f *listFilter) []Entry {
+	if !f.active() {
+		return entries
+	}
+	var res []Entry
+	for _, e := range entries {
+		if f.re.MatchString(e.name) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// setEntries задаёт полный список панели и пересчитывает видимую часть
+func setEntries(p *Panel, entries []Entry) {
+	p.all = entries
+	p.items = panelItems(p)
+}
+
+// refilter применяет изменившийся фильтр, по возможности не сдвигая курсор
+func refilter(p *Panel) {
+	name := ""
+	if e := selectedEntry(p); e != nil {
+		name = e.name
+	}
+	p.items = panelItems(p)
+	if !selectName(p, name) {
+		p.cursor = 0
+		p.offset = 0
+		ensureCursorBounds(p)
+	}
+}
+
+// switchFilter при переходе в другой каталог убирает незакреплённый фильтр
+// и восстанавливает закреплённый за новым каталогом
+func switchFilter(p *Panel, newPath string) {
+	if p.filter != nil && p.filter.pinned {
+		dirFilters[p.path] = p.filter
+	} else {
+		delete(dirFilters, p.path)
+	}
+	p.filter = dirFilters[newPath]
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- fuzzy finder ----------------
+// finder ищет файлы и каталоги под корнем по нечёткому совпадению: дерево
+// обходится в фоне, результаты ранжируются заново при каждом нажатии.
+const (
+	finderMaxPaths   = 200000 // больше не собираем, чтобы не съесть память в огромных деревьях
+	finderMaxMatches = 1000   // в списке держим только лучшие совпадения, остальные лишь считаем
+)
+
+type finderPath struct {
+	rel string // путь от корня через '/'
+	dir bool
+}
+
+type finderMatch struct {
+	idx   int // индекс в finder.paths
+	score int
+	pos   []int // позиции совпавших рун для подсветки
+}
+
+type finder struct {
+	root      string
+	gen       int
+	paths     []finderPath
+	query     string
+	matches   []finderMatch // лучшие по убыванию, не больше finderMaxMatches
+	matched   int           // всего совпадений
+	cursor    int
+	offset    int
+	walking   bool
+	truncated bool
+	cancel    chan struct{}
+}
+
+// finderBatchEvent несёт очередную порцию найденных путей
+type finderBatchEvent struct {
+	when  time.Time
+	gen   int
+	paths []finderPath
+	done  bool
+}
+
+func (e *finderBatchEvent) When() time.Time { return e.when }
+
+var finderGen int
+
+func startFinder(root string, out chan<- tcell.Event) *finder {
+	finderGen++
+	f := &finder{root: root, gen: finderGen, walking: true, cancel: make(chan struct{})}
+	go walkFinder(root, f.gen, showHidden, f.cancel, out)
+	return f
+}
+
+func (f *finder) stop() {
+	close(f.cancel)
+}
+
+func walkFinder(root string, gen int, hidden bool, cancel <-chan struct{}, out chan<- tcell.Event) {
+	send := func(paths []finderPath, done bool) bool {
+		select {
+		case out <- &finderBatchEvent{when: time.Now(), gen: gen, paths: paths, done: done}:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	var batch []finderPath
+	last := time.Now()
+	total := 0
+	stopped := false
+	walkTree(root, hidden, cancel, func(rel string, d fs.DirEntry) bool {
+		batch = append(batch, finderPath{rel: rel, dir: d.IsDir()})
+		total++
+		if total >= finderMaxPaths {
+			return false
+		}
+		if time.Since(last) >= loadFlushTime {
+			if !send(batch, false) {
+				stopped = true
+				return false
+			}
+			batch = nil
+			last = time.Now()
+		}
+		return true
+	})
+	if !stopped {
+		send(batch, true)
+	}
+}
+
+// apply добавляет порцию путей и ранжирует только их
+func (f *finder) apply(ev *finderBatchEvent) {
+	from := len(f.paths)
+	f.paths = append(f.paths, ev.paths...)
+	if ev.done {
+		f.walking = false
+		f.truncated = len(f.paths) >= finderMaxPaths
+	}
+	f.rank(from)
+}
+
+func (f *finder) setQuery(q string) {
+	f.query = q
+	f.matches = nil
+	f.matched = 0
+	f.cursor = 0
+	f.offset = 0
+	f.rank(0)
+}
+
+// rank сопоставляет пути начиная с from. Новые совпадения отбираются кучей
+// ограниченного размера и вливаются в уже отсортированный список, так что ни
+// порция обхода, ни нажатие не сортируют все найденные пути.
+func (f *finder) rank(from int) {
+	pattern := []rune(f.query)
+	if len(pattern) == 0 {
+		// без запроса — порядок обхода
+		for i := from; i < len(f.paths); i++ {
+			f.matches = append(f.matches, finderMatch{idx: i})
+		}
+		f.matched = len(f.matches)
+		return
+	}
+	caseSensitive := hasUpper(f.query)
+	h := &matchHeap{f: f}
+	for i := from; i < len(f.paths); i++ {
+		score, pos, ok := fuzzyMatch([]rune(f.paths[i].rel), pattern, caseSensitive)
+		if !ok {
+			continue
+		}
+		f.matched++
+		m := finderMatch{idx: i, score: score, pos: pos}
+		if h.Len() < finderMaxMatches {
+			heap.Push(h, m)
+		} else if f.better(m, h.items[0]) {
+			h.items[0] = m
+			heap.Fix(h, 0)
+		}
+	}
+	slices.SortFunc(h.items, func(a, b finderMatch) int {
+		if f.better(a, b) {
+			return -1
+		}
+		return 1
+	})
+	f.merge(h.items)
+}
+
+// better — порядок результатов: выше счёт, при равном — короче путь, затем раньше найденный
+func (f *finder) better(a, b finderMatch) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if la, lb := len(f.paths[a.idx].rel), len(f.paths[b.idx].rel); la != lb {
+		return la < lb
+	}
+	return a.idx < b.idx
+}
+
+// merge сливает отсортированные add с matches, оставляя finderMaxMatches лучших
+func (f *finder) merge(add []finderMatch) {
+	res := make([]finderMatch, 0, min(len(f.matches)+len(add), finderMaxMatches))
+	i, j := 0, 0
+	for len(res) < finderMaxMatches && (i < len(f.matches) || j < len(add)) {
+		if j >= len(add) || i < len(f.matches) && f.better(f.matches[i], add[j]) {
+			res = append(res, f.matches[i])
+			i++
+		} else {
+			res = append(res, add[j])
+			j++
+		}
+	}
+	f.matches = res
+}
+
+// matchHeap держит худшее из отобранных совпадений на вершине
+type matchHeap struct {
+	f     *finder
+	items []finderMatch
+}
+
+func (h *matchHeap) Len() int           { return len(h.items) }
+func (h *matchHeap) Less(i, j int) bool { return h.f.better(h.items[j], h.items[i]) }
+func (h *matchHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *matchHeap) Push(x any)         { h.items = append(h.items, x.(finderMatch)) }
+func (h *matchHeap) Pop() any {
+	m := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return m
+}
+
+func (f *finder) move(delta int) {
+	f.cursor += delta
+	if f.cursor >= len(f.matches) {
+		f.cursor = len(f.matches) - 1
+	}
+	if f.cursor < 0 {
+		f.cursor = 0
+	}
+	if f.cursor < f.offset {
+		f.offset = f.cursor
+	}
+	if f.cursor >= f.offset+listPopupRows {
+		f.offset = f.cursor - listPopupRows + 1
+	}
+}
+
+// selected возвращает полный путь выбранного результата
+func (f *finder) selected() (string, bool) {
+	if f.cursor < 0 || f.cursor >= len(f.matches) {
+		return "", false
+	}
+	return filepath.Join(f.root, filepath.FromSlash(f.paths[f.matches[f.cursor].idx].rel)), true
+}
+
+// finderKey обрабатывает ввод запроса и перемещение; Enter и ESC решает вызывающий
+func finderKey(f *finder, ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyUp, tcell.KeyCtrlP:
+		f.move(-1)
+	case tcell.KeyDown, tcell.KeyCtrlN:
+		f.move(1)
+	case tcell.KeyPgUp:
+		f.move(-listPopupRows)
+	case tcell.KeyPgDn:
+		f.move(listPopupRows)
+	case tcell.KeyCtrlU:
+		f.setQuery("")
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if r := []rune(f.query); len(r) > 0 {
+			f.setQuery(string(r[:len(r)-1]))
+		}
+	case tcell.KeyRune:
+		f.setQuery(f.query + string(ev.Rune()))
+	}
+}
+
+// ---------------- scoring ----------------
+// Оценка в духе fzf: очки за каждое совпадение, бонусы за начало слова
+// (после '/', '_', '-', '.', пробела, смены регистра) и за идущие подряд
+// символы, штрафы за пропуски.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	bonusBoundary     = scoreMatch / 2
+	bonusSeparator    = scoreMatch/2 + 2 // сразу после '/'
+	bonusCamel        = bonusBoundary - 1
+	bonusConsecutive  = 4
+	bonusFirstFactor  = 2
+)
+
+func charBonus(prev, cur rune) int {
+	switch {
+	case prev == '/':
+		return bonusSeparator
+	case prev == '_' || prev == '-' || prev == '.' || prev == ' ':
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	case !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+// fuzzyMatch ищет pattern в text как подпоследовательность. Сначала жадно
+// находит конец первого вхождения, затем идёт назад, сужая окно, и считает
+// очки в нём.
+func fuzzyMatch(text, pattern []rune, caseSensitive bool) (int, []int, bool) {
+	fold := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+	pi := 0
+	end := -1
+	for i, r := range text {
+		if fold(r) == fold(pattern[pi]) {
+			pi++
+			if pi == len(pattern) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	start := end
+	pi = len(pattern) - 1
+	for i := end; i >= 0; i-- {
+		if fold(text[i]) == fold(pattern[pi]) {
+			pi--
+			if pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	score := 0
+	pos := make([]int, 0, len(pattern))
+	pi = 0
+	inGap := false
+	consecutive := 0
+	firstBonus := 0
+	for i := start; i <= end; i++ {
+		prev := '/'
+		if i > 0 {
+			prev = text[i-1]
+		}
+		if pi < len(pattern) && fold(text[i]) == fold(pattern[pi]) {
+			bonus := charBonus(prev, text[i])
+			if consecutive == 0 {
+				firstBonus = bonus
+			} else {
+				// продолжение цепочки наследует бонус её начала
+				bonus = max(bonus, firstBonus, bonusConsecutive)
+			}
+			if pi == 0 {
+				bonus *= bonusFirstFactor
+			}
+			score += scoreMatch + bonus
+			pos = append(pos, i)
+			pi++
+			consecutive++
+			inGap = false
+			continue
+		}
+		consecutive = 0
+		if inGap {
+			score += scoreGapExtension
+		} else {
+			score += scoreGapStart
+			inGap = true
+		}
+	}
+	// совпадение в имени, а не в каталогах, ценнее
+	if slices.Index(text[pos[0]:], '/') < 0 {
+		score += bonusBoundary
+	}
+	return score, pos, true
+}
+
+// ---------------- drawing ----------------
+func drawFinder(s tcell.Screen, f *finder) {
+	sw, sh := s.Size()
+	w := sw * 3 / 4
+	if w < 40 {
+		w = 40
+	}
+	if w > sw {
+		w = sw
+	}
+	h := listPopupRows + 6
+	if h > sh {
+		h = sh
+	}
+	x := (sw - w) / 2
+	y := (sh - h) / 2
+
+	drawBox(s, x, y, w, h)
+	headerStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	grayStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	dirStyle := tcell.StyleDefault.Foreground(tcell.ColorBlue)
+
+	state := ""
+	if f.walking {
+		state = " scanning…"
+	} else if f.truncated {
+		state = " (limit reached)"
+	}
+	counter := fmt.Sprintf("%d/%d%s", f.matched, len(f.paths), state)
+	drawText(s, x+2, y+1, "Find in "+displayPath(f.root), headerStyle, w-6-len([]rune(counter)))
+	drawText(s, x+w-2-len([]rune(counter)), y+1, counter, grayStyle, len([]rune(counter)))
+
+	prompt := "> " + f.query
+	drawText(s, x+2, y+2, prompt, textStyle, w-4)
+	s.SetContent(x+2+len([]rune(prompt)), y+2, ' ', nil, textStyle.Reverse(true))
+
+	rows := h - 5
+	for i := 0; i < rows && f.offset+i < len(f.matches); i++ {
+		m := f.matches[f.offset+i]
+		fp := f.paths[m.idx]
+		text := fp.rel
+		style := textStyle
+		if fp.dir {
+			text += "/"
+			style = dirStyle
+		}
+		marks := map[int]bool{}
+		for _, p := range m.pos {
+			marks[p] = true
+		}
+		selected := f.offset+i == f.cursor
+		runes := []rune(text)
+		for j := 0; j < w-4; j++ {
+			r := ' '
+			if j < len(runes) {
+				r = runes[j]
+			}
+			st := style
+			if marks[j] {
+				st = st.Foreground(tcell.ColorYellow).Bold(true)
+			}
+			if selected {
+				st = st.Reverse(true)
+			}
+			s.SetContent(x+2+j, y+4+i, r, nil, st)
+		}
+	}
+}
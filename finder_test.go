@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		text, pattern string
+		caseSensitive bool
+		ok            bool
+		pos           []int
+	}{
+		{"main.go", "mgo", false, true, []int{0, 5, 6}},
+		{"main.go", "xyz", false, false, nil},
+		{"main.go", "og", false, false, nil},
+		{"Main.go", "main", false, true, []int{0, 1, 2, 3}},
+		{"Main.go", "main", true, false, nil},
+		{"Main.go", "Main", true, true, []int{0, 1, 2, 3}},
+		// окно сужается к последнему вхождению первого символа
+		{"a/a/abc", "abc", false, true, []int{4, 5, 6}},
+		{"каталог/файл", "кф", false, true, []int{0, 8}},
+	}
+	for _, tt := range tests {
+		_, pos, ok := fuzzyMatch([]rune(tt.text), []rune(tt.pattern), tt.caseSensitive)
+		if ok != tt.ok {
+			t.Errorf("fuzzyMatch(%q, %q) ok = %v, want %v", tt.text, tt.pattern, ok, tt.ok)
+			continue
+		}
+		if ok && !slices.Equal(pos, tt.pos) {
+			t.Errorf("fuzzyMatch(%q, %q) pos = %v, want %v", tt.text, tt.pattern, pos, tt.pos)
+		}
+	}
+}
+
+func TestFuzzyMatchRanking(t *testing.T) {
+	// первый вариант в каждой паре должен получить больше очков
+	tests := []struct {
+		better, worse, pattern string
+	}{
+		{"foo/bar", "fxxbxx", "fb"},
+		{"src/main.go", "srcmxaxixn.go", "main"},
+		{"fooBar", "foobar", "b"},
+		{"my_file", "myxfile", "f"},
+		{"abc", "axbxc", "abc"},
+	}
+	for _, tt := range tests {
+		a, _, okA := fuzzyMatch([]rune(tt.better), []rune(tt.pattern), false)
+		b, _, okB := fuzzyMatch([]rune(tt.worse), []rune(tt.pattern), false)
+		if !okA || !okB {
+			t.Errorf("fuzzyMatch(%q / %q, %q): no match", tt.better, tt.worse, tt.pattern)
+			continue
+		}
+		if a <= b {
+			t.Errorf("score(%q) = %d, score(%q) = %d for %q; want the first higher", tt.better, a, tt.worse, b, tt.pattern)
+		}
+	}
+}
+
+func TestFinderRank(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const letters = "abcdef/_."
+	var paths []finderPath
+	for i := 0; i < 3*finderMaxMatches; i++ {
+		n := 3 + rng.Intn(12)
+		b := make([]byte, n)
+		for j := range b {
+			b[j] = letters[rng.Intn(len(letters))]
+		}
+		paths = append(paths, finderPath{rel: string(b)})
+	}
+
+	for _, q := range []string{"a", "ab", "a/c", "fed"} {
+		// ожидаемое — полная сортировка всех совпадений
+		f := &finder{paths: paths, query: q}
+		var all []finderMatch
+		for i, p := range paths {
+			if score, _, ok := fuzzyMatch([]rune(p.rel), []rune(q), false); ok {
+				all = append(all, finderMatch{idx: i, score: score})
+			}
+		}
+		sort.SliceStable(all, func(i, j int) bool {
+			a, b := all[i], all[j]
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			return len(paths[a.idx].rel) < len(paths[b.idx].rel)
+		})
+		if len(all) > finderMaxMatches {
+			all = all[:finderMaxMatches]
+		}
+
+		// порциями, как приходят из обхода
+		inc := &finder{query: q}
+		for from := 0; from < len(paths); from += 337 {
+			inc.apply(&finderBatchEvent{paths: paths[from:min(from+337, len(paths))]})
+		}
+		f.setQuery(q)
+		for name, got := range map[string]*finder{"setQuery": f, "batches": inc} {
+			if len(got.matches) != len(all) {
+				t.Errorf("%s %q: %d matches, want %d", name, q, len(got.matches), len(all))
+				continue
+			}
+			for i := range all {
+				if got.matches[i].idx != all[i].idx {
+					t.Errorf("%s %q: match %d = %s, want %s", name, q, i,
+						describeMatch(got, got.matches[i]), describeMatch(got, all[i]))
+					break
+				}
+			}
+		}
+		if f.matched != inc.matched || f.matched < len(all) {
+			t.Errorf("%q: matched = %d / %d, want equal and >= %d", q, f.matched, inc.matched, len(all))
+		}
+	}
+}
+
+func describeMatch(f *finder, m finderMatch) string {
+	return fmt.Sprintf("%q(%d)", f.paths[m.idx].rel, m.score)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------- frecency ----------------
+// Посещённые каталоги с весом и временем последнего визита, как в zoxide:
+// каждый визит добавляет 1 к весу, при ранжировании вес умножается на
+// коэффициент свежести. Когда сумма весов превышает frecencyMaxAge, все веса
+// уменьшаются, а забытые каталоги выбрасываются.
+const frecencyMaxAge = 10000
+
+// frecencySaveInterval — как часто визиты сбрасываются на диск; остальное
+// сохраняется при выходе
+const frecencySaveInterval = 30 * time.Second
+
+// frecencyImportAge — давность визита для импортированных каталогов: вывод
+// zoxide и база autojump времени последнего захода не содержат
+const frecencyImportAge = 7 * 24 * time.Hour
+
+type frecencyEntry struct {
+	Rank float64 `json:"rank"`
+	Last int64   `json:"last"` // unix-время последнего визита
+}
+
+var (
+	frecency      = map[string]*frecencyEntry{}
+	frecencyDirty bool
+	frecencySaved time.Time
+)
+
+func frecencyFile() string {
+	return filepath.Join(homeDir, ".myfm_frecency.json")
+}
+
+func loadFrecency() {
+	data, err := os.ReadFile(frecencyFile())
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &frecency)
+}
+
+func saveFrecency() {
+	data, _ := json.MarshalIndent(frecency, "", "  ")
+	_ = os.WriteFile(frecencyFile(), data, 0644)
+}
+
+// recordVisit учитывает заход в каталог; удалённые адреса не запоминаем,
+// в них может быть пароль
+func recordVisit(dir string) {
+	if isRemote(dir) || dir == "" {
+		return
+	}
+	e := frecency[dir]
+	if e == nil {
+		e = &frecencyEntry{}
+		frecency[dir] = e
+	}
+	e.Rank++
+	e.Last = time.Now().Unix()
+	ageFrecency()
+	frecencyDirty = true
+	if time.Since(frecencySaved) >= frecencySaveInterval {
+		flushFrecency()
+	}
+}
+
+// flushFrecency сохраняет несохранённые визиты
+func flushFrecency() {
+	if !frecencyDirty {
+		return
+	}
+	saveFrecency()
+	frecencyDirty = false
+	frecencySaved = time.Now()
+}
+
+func ageFrecency() {
+	total := 0.0
+	for _, e := range frecency {
+		total += e.Rank
+	}
+	if total <= frecencyMaxAge {
+		return
+	}
+	k := 0.9 * frecencyMaxAge / total
+	for dir, e := range frecency {
+		e.Rank *= k
+		if e.Rank < 1 {
+			delete(frecency, dir)
+		}
+	}
+}
+
+func frecencyScore(e *frecencyEntry, now int64) float64 {
+	age := time.Duration(now-e.Last) * time.Second
+	switch {
+	case age < time.Hour:
+		return e.Rank * 4
+	case age < 24*time.Hour:
+		return e.Rank * 2
+	case age < 7*24*time.Hour:
+		return e.Rank / 2
+	}
+	return e.Rank / 4
+}
+
+// frecencyMatch проверяет, что слова запроса встречаются в пути по порядку,
+// а последнее — в имени самого каталога
+func frecencyMatch(dir string, words []string) bool {
+	if len(words) == 0 {
+		return true
+	}
+	lower := strings.ToLower(dir)
+	pos := 0
+	for _, w := range words {
+		i := strings.Index(lower[pos:], w)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(w)
+	}
+	return strings.Contains(strings.ToLower(filepath.Base(dir)), words[len(words)-1])
+}
+
+// frecencyRank возвращает подходящие под запрос каталоги, лучшие первыми;
+// исчезнувшие каталоги пропускаются, current — тоже
+func frecencyRank(query, current string) []string {
+	words := strings.Fields(strings.ToLower(query))
+	now := time.Now().Unix()
+	type scored struct {
+		dir   string
+		score float64
+	}
+	var res []scored
+	for dir, e := range frecency {
+		if dir == current || !frecencyMatch(dir, words) {
+			continue
+		}
+		res = append(res, scored{dir, frecencyScore(e, now)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].score != res[j].score {
+			return res[i].score > res[j].score
+		}
+		return res[i].dir < res[j].dir
+	})
+	var dirs []string
+	for _, r := range res {
+		if info, err := os.Stat(r.dir); err == nil && info.IsDir() {
+			dirs = append(dirs, r.dir)
+		}
+	}
+	return dirs
+}
+
+// ---------------- import ----------------
+// importFrecency добавляет каталоги из базы zoxide или autojump; file —
+// необязательный путь к базе autojump
+func importFrecency(source, file string) (int, error) {
+	var lines []string
+	switch source {
+	case "zoxide":
+		out, err := exec.Command("zoxide", "query", "--list", "--score").Output()
+		if err != nil {
+			return 0, fmt.Errorf("zoxide: %v", err)
+		}
+		lines = strings.Split(string(out), "\n")
+	case "autojump":
+		if file == "" {
+			file = autojumpFile()
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+	default:
+		return 0, fmt.Errorf("unknown source %q (use zoxide or autojump)", source)
+	}
+
+	// оба формата — "вес<пробел или таб>путь"
+	last := time.Now().Add(-frecencyImportAge).Unix()
+	n := 0
+	for _, line := range lines {
+		fields := strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		if len(fields) != 2 {
+			fields = strings.SplitN(strings.TrimSpace(line), " ", 2)
+		}
+		if len(fields) != 2 {
+			continue
+		}
+		rank, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		dir := strings.TrimSpace(fields[1])
+		if err != nil || !filepath.IsAbs(dir) {
+			continue
+		}
+		e := frecency[dir]
+		if e == nil {
+			e = &frecencyEntry{}
+			frecency[dir] = e
+		}
+		e.Rank += rank
+		e.Last = max(e.Last, last)
+		n++
+	}
+	ageFrecency()
+	saveFrecency()
+	return n, nil
+}
+
+func autojumpFile() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "autojump", "autojump.txt")
+	}
+	mac := filepath.Join(homeDir, "Library", "autojump", "autojump.txt")
+	if _, err := os.Stat(mac); err == nil {
+		return mac
+	}
+	return filepath.Join(homeDir, ".local", "share", "autojump", "autojump.txt")
+}
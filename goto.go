@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------- go to path ----------------
+// expandPath раскрывает ~ и переменные окружения; адреса WebDAV не трогает
+func expandPath(p string) string {
+	if isRemote(p) {
+		return p
+	}
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = homeDir + p[1:]
+	}
+	return os.ExpandEnv(p)
+}
+
+// resolvePath превращает введённый путь в абсолютный относительно dir
+func resolvePath(input, dir string) string {
+	p := expandPath(strings.TrimSpace(input))
+	if !isRemote(p) && !filepath.IsAbs(p) {
+		p = joinPath(dir, p)
+	}
+	return normalizePath(p)
+}
+
+// completePath дополняет ввод именами подкаталогов, а также путями из
+// закладок и истории, в которых встречается введённый текст
+func completePath(input, dir string, known []string) []string {
+	var res []string
+	seen := map[string]bool{}
+	add := func(c string) {
+		if !seen[c] {
+			seen[c] = true
+			res = append(res, c)
+		}
+	}
+
+	// подкаталоги: дописываем имя к введённому префиксу, не раскрывая ~ и $VAR
+	if !isRemote(input) {
+		prefixDir, partial := "", input
+		if i := strings.LastIndex(input, "/"); i >= 0 {
+			prefixDir, partial = input[:i+1], input[i+1:]
+		}
+		listDir := dir
+		if prefixDir != "" {
+			listDir = resolvePath(prefixDir, dir)
+		}
+		if entries, err := os.ReadDir(listDir); err == nil {
+			var names []string
+			for _, e := range entries {
+				name := e.Name()
+				if !strings.HasPrefix(name, partial) {
+					continue
+				}
+				if isHiddenName(name) && !showHidden && !strings.HasPrefix(partial, ".") {
+					continue
+				}
+				if info, err := os.Stat(filepath.Join(listDir, name)); err == nil && info.IsDir() {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, n := range names {
+				add(prefixDir + n + "/")
+			}
+		}
+	}
+
+	needle := strings.ToLower(expandPath(input))
+	for _, k := range known {
+		if needle != "" && strings.Contains(strings.ToLower(k), needle) {
+			add(k)
+		}
+	}
+	return res
+}
+
+func commonPrefix(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	prefix := items[0]
+	for _, it := range items[1:] {
+		for !strings.HasPrefix(it, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
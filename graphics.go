@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
+)
+
+// ---------------- terminal graphics ----------------
+// Терминалы с протоколом kitty или sixel получают картинку настоящими
+// пикселями поверх пустых клеток просмотра. Последовательности пишутся прямо
+// в tty после s.Show(), и только когда картинка или её место меняются:
+// пустые клетки под ней tcell сам не перерисовывает.
+
+// detectImageProtocol выбирает способ вывода по config.ImageProtocol. auto
+// сначала спрашивает сам терминал: переменные окружения по ssh обычно не
+// передаются, а внутри GNU screen TERM остаётся от внешнего терминала. Молчащий
+// терминал выбирается по переменным, которыми терминалы себя объявляют. Внутри
+// tmux и screen графика не проходит, там всегда полублоки. Вызывается до
+// s.Init, пока терминал не принадлежит tcell.
+func detectImageProtocol() string {
+	switch config.ImageProtocol {
+	case "halfblocks", "kitty", "sixel":
+		return config.ImageProtocol
+	}
+	if os.Getenv("TMUX") != "" || os.Getenv("STY") != "" {
+		return "halfblocks"
+	}
+	if proto, ok := queryImageProtocol(); ok {
+		return proto
+	}
+	term, prog := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(term, "kitty") || prog == "WezTerm" || prog == "ghostty":
+		return "kitty"
+	case strings.Contains(term, "sixel") || term == "foot" || strings.HasPrefix(term, "mlterm") || term == "yaft-256color":
+		return "sixel"
+	}
+	return "halfblocks"
+}
+
+const (
+	// запрос kitty о поддержке графики (картинка 1×1 без вывода) и DA1,
+	// на который отвечает любой терминал, — по его ответу видно, что
+	// ждать больше нечего
+	termGraphicsQuery   = "\x1b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\\x1b[c"
+	termGraphicsTimeout = 500 * time.Millisecond
+)
+
+var da1Reply = regexp.MustCompile(`\x1b\[\?([0-9;]*)c`)
+
+// queryImageProtocol спрашивает терминал, какую графику он понимает; ok —
+// терминал ответил
+func queryImageProtocol() (proto string, ok bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false
+	}
+	defer tty.Close()
+	// Fd() перевёл бы файл в блокирующий режим и таймаут чтения не работал бы
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return "", false
+	}
+	var state *term.State
+	conn.Control(func(fd uintptr) {
+		state, err = term.MakeRaw(int(fd))
+	})
+	if err != nil {
+		return "", false
+	}
+	defer conn.Control(func(fd uintptr) {
+		term.Restore(int(fd), state)
+	})
+
+	if _, err := tty.WriteString(termGraphicsQuery); err != nil {
+		return "", false
+	}
+	if tty.SetReadDeadline(time.Now().Add(termGraphicsTimeout)) != nil {
+		return "", false
+	}
+	var reply []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if proto, ok := parseGraphicsReply(reply); ok || err != nil {
+			return proto, ok
+		}
+	}
+}
+
+// parseGraphicsReply разбирает ответы терминала на termGraphicsQuery; ok —
+// пришёл ответ на DA1, значит ответ kitty (если он есть) пришёл раньше
+func parseGraphicsReply(reply []byte) (proto string, ok bool) {
+	m := da1Reply.FindSubmatch(reply)
+	if m == nil {
+		return "", false
+	}
+	switch {
+	case bytes.Contains(reply, []byte("\x1b_Gi=31;OK")):
+		return "kitty", true
+	case slices.Contains(strings.Split(string(m[1]), ";"), "4"):
+		// атрибут 4 в ответе DA1 — поддержка sixel
+		return "sixel", true
+	}
+	return "halfblocks", true
+}
+
+type termGraphics struct {
+	proto   string
+	shown   string // что выведено сейчас: картинка и место; "" — ничего
+	shownID int
+	sent    map[int]bool // kitty: картинки, уже переданные терминалу
+	lastID  int
+}
+
+func newTermGraphics(proto string) *termGraphics {
+	return &termGraphics{proto: proto, sent: map[int]bool{}}
+}
+
+// pixels сообщает, что картинки выводятся пикселями, а не полублоками
+func (g *termGraphics) pixels() bool {
+	return g.proto == "kitty" || g.proto == "sixel"
+}
+
+// cellSize — размер клетки в пикселях; если терминал его не сообщает,
+// берём типичный
+func cellSize(s tcell.Screen) (int, int) {
+	if tty, ok := s.Tty(); ok {
+		if ws, err := tty.WindowSize(); err == nil {
+			if cw, ch := ws.CellDimensions(); cw > 0 && ch > 0 {
+				return cw, ch
+			}
+		}
+	}
+	return 10, 20
+}
+
+// update выводит img в области клеток x, y, w, h; nil убирает картинку.
+// Вызывается после s.Show().
+func (g *termGraphics) update(s tcell.Screen, img *imageInfo, x, y, w, h int) {
+	if !g.pixels() {
+		return
+	}
+	tty, ok := s.Tty()
+	if !ok {
+		return
+	}
+	key := ""
+	if img != nil {
+		if img.id == 0 {
+			g.lastID++
+			img.id = g.lastID
+		}
+		key = fmt.Sprintf("%d:%d,%d,%d,%d", img.id, x, y, w, h)
+	}
+	if key == g.shown {
+		return
+	}
+
+	var b bytes.Buffer
+	if g.shown != "" {
+		// старую картинку убираем: у kitty — удалением размещения, у sixel —
+		// полной перерисовкой экрана поверх пикселей. Если на её месте будет
+		// другая картинка или ничего, kitty освобождает и данные (d=I), иначе
+		// они копились бы в памяти терминала с каждым просмотренным файлом.
+		if g.proto == "kitty" {
+			if img != nil && img.id == g.shownID {
+				fmt.Fprintf(&b, "\x1b_Ga=d,d=i,i=%d,q=2\x1b\\", g.shownID)
+			} else {
+				fmt.Fprintf(&b, "\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", g.shownID)
+				delete(g.sent, g.shownID)
+			}
+		} else {
+			s.Sync()
+		}
+	}
+	g.shown = key
+	if img == nil {
+		tty.Write(b.Bytes())
+		return
+	}
+	g.shownID = img.id
+
+	cellW, cellH := cellSize(s)
+	pw, ph := fitSize(img.w, img.h, w*cellW, h*cellH, true)
+	cols, rows := (pw+cellW-1)/cellW, (ph+cellH-1)/cellH
+	ox, oy := (w-cols)/2, (h-rows)/2
+	// курсор сохраняем и возвращаем, чтобы не сбить tcell
+	fmt.Fprintf(&b, "\x1b7\x1b[%d;%dH", y+oy+1, x+ox+1)
+	if g.proto == "kitty" {
+		if g.sent[img.id] {
+			fmt.Fprintf(&b, "\x1b_Ga=p,i=%d,p=1,c=%d,r=%d,C=1,q=2\x1b\\", img.id, cols, rows)
+		} else {
+			g.sent[img.id] = true
+			writeKitty(&b, img.thumb, img.id, cols, rows)
+		}
+	} else {
+		sk := fmt.Sprintf("%dx%d", pw, ph)
+		if img.sixelKey != sk {
+			img.sixel = encodeSixel(resizeImage(img.thumb, pw, ph))
+			img.sixelKey = sk
+		}
+		b.Write(img.sixel)
+	}
+	b.WriteString("\x1b8")
+	tty.Write(b.Bytes())
+}
+
+// writeKitty передаёт картинку в PNG кусками по 4096 символов base64 и сразу
+// размещает её в cols×rows клетках
+func writeKitty(b *bytes.Buffer, img *image.RGBA, id, cols, rows int) {
+	var data bytes.Buffer
+	_ = png.Encode(&data, img)
+	enc := base64.StdEncoding.EncodeToString(data.Bytes())
+	for first := true; first || enc != ""; first = false {
+		chunk := enc[:min(4096, len(enc))]
+		enc = enc[len(chunk):]
+		more := 0
+		if enc != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(b, "\x1b_Ga=T,f=100,i=%d,p=1,c=%d,r=%d,C=1,q=2,m=%d;%s\x1b\\", id, cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+}
+
+// encodeSixel кодирует картинку в sixel с палитрой 6×6×6. Картинка идёт
+// полосами по шесть строк; в полосе для каждого цвета — строка символов,
+// повторы сжимаются как !N<символ>.
+func encodeSixel(img *image.RGBA) []byte {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\x1bPq\"1;1;%d;%d", w, h)
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	level := func(v uint8) int { return (int(v)*5 + 127) / 255 }
+	idx := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.RGBAAt(x, y)
+			idx[y*w+x] = uint8(level(c.R)*36 + level(c.G)*6 + level(c.B))
+		}
+	}
+	row := make([]byte, w)
+	for band := 0; band < h; band += 6 {
+		var used [216]bool
+		for k := band; k < band+6 && k < h; k++ {
+			for _, c := range idx[k*w : (k+1)*w] {
+				used[c] = true
+			}
+		}
+		first := true
+		for c := range used {
+			if !used[c] {
+				continue
+			}
+			if !first {
+				b.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&b, "#%d", c)
+			for x := 0; x < w; x++ {
+				bits := 0
+				for k := 0; k < 6 && band+k < h; k++ {
+					if int(idx[(band+k)*w+x]) == c {
+						bits |= 1 << k
+					}
+				}
+				row[x] = byte(63 + bits)
+			}
+			for x := 0; x < w; {
+				n := 1
+				for x+n < w && row[x+n] == row[x] {
+					n++
+				}
+				if n > 3 {
+					fmt.Fprintf(&b, "!%d%c", n, row[x])
+				} else {
+					b.Write(row[x : x+n])
+				}
+				x += n
+			}
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	return b.Bytes()
+}
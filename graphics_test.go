@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseGraphicsReply(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+		ok    bool
+	}{
+		{"\x1b_Gi=31;OK\x1b\\\x1b[?62;c", "kitty", true},
+		{"\x1b_Gi=31;ENOTSUPPORTED:no graphics\x1b\\\x1b[?62;c", "halfblocks", true},
+		{"\x1b[?62;4;6;22c", "sixel", true},
+		{"\x1b[?64;1;2;6;9;15;22c", "halfblocks", true},
+		{"\x1b[?1;2c", "halfblocks", true},
+		// атрибут 42 — не sixel
+		{"\x1b[?62;42c", "halfblocks", true},
+		// ответ kitty пришёл, DA1 ещё нет — ждём дальше
+		{"\x1b_Gi=31;OK\x1b\\", "", false},
+		{"\x1b[?62;", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseGraphicsReply([]byte(tt.reply))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseGraphicsReply(%q) = %q, %v; want %q, %v", tt.reply, got, ok, tt.want, tt.ok)
+		}
+	}
+}
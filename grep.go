@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- content search ----------------
+// grep ищет текст в файлах под корнем: обход дерева кормит несколько
+// воркеров, найденные строки порциями приходят в общий канал событий.
+const (
+	grepMaxFileSize = 16 << 20 // файлы крупнее пропускаем
+	grepMaxHits     = 10000    // дальше результаты не собираем
+	grepSniffSize   = 8000     // столько байт смотрим в поисках NUL
+	grepMaxLine     = 1 << 20  // более длинные строки не разбираем
+
+	grepSnippetBefore = 80  // байт строки перед совпадением, которые храним
+	grepSnippetSize   = 300 // всего байт строки на одно совпадение
+)
+
+type grepHit struct {
+	rel  string // путь от корня через '/'
+	line int
+	text string
+	span [2]int // байтовый отрезок совпадения в text
+}
+
+type grepSearch struct {
+	root      string
+	query     string
+	gen       int
+	hits      []grepHit
+	files     int // просмотрено файлов
+	cursor    int
+	offset    int
+	running   bool
+	truncated bool
+	cancel    chan struct{}
+}
+
+// grepEvent несёт очередную порцию совпадений
+type grepEvent struct {
+	when  time.Time
+	gen   int
+	hits  []grepHit
+	files int
+	done  bool
+}
+
+func (e *grepEvent) When() time.Time { return e.when }
+
+var grepGen int
+
+// grepPattern строит выражение: текст ищется буквально, а /…/ — как регулярное
+// выражение; регистр учитывается, только если в запросе есть заглавные
+func grepPattern(query string) (*regexp.Regexp, error) {
+	expr := regexp.QuoteMeta(query)
+	if len(query) > 2 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/") {
+		expr = query[1 : len(query)-1]
+	}
+	if !hasUpper(query) {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+func startGrep(root, query string, out chan<- tcell.Event) (*grepSearch, error) {
+	re, err := grepPattern(query)
+	if err != nil {
+		return nil, err
+	}
+	grepGen++
+	g := &grepSearch{root: root, query: query, gen: grepGen, running: true, cancel: make(chan struct{})}
+	go runGrep(root, re, g.gen, showHidden, g.cancel, out)
+	return g, nil
+}
+
+func (g *grepSearch) stop() {
+	close(g.cancel)
+}
+
+func runGrep(root string, re *regexp.Regexp, gen int, hidden bool, cancel <-chan struct{}, out chan<- tcell.Event) {
+	// quit останавливает обход и воркеры и при отмене, и при достижении лимита
+	quit := make(chan struct{})
+	defer close(quit)
+	files := make(chan string, 64)
+	results := make(chan []grepHit, 64)
+
+	go func() {
+		walkTree(root, hidden, quit, func(rel string, d fs.DirEntry) bool {
+			if !d.Type().IsRegular() {
+				return true
+			}
+			select {
+			case files <- rel:
+				return true
+			case <-quit:
+				return false
+			}
+		})
+		close(files)
+	}()
+
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range files {
+				hits := grepFile(root, rel, re)
+				select {
+				case results <- hits:
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	send := func(hits []grepHit, files int, done bool) bool {
+		select {
+		case out <- &grepEvent{when: time.Now(), gen: gen, hits: hits, files: files, done: done}:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+	var batch []grepHit
+	scanned, total := 0, 0
+	last := time.Now()
+	for {
+		var hits []grepHit
+		var ok bool
+		select {
+		case hits, ok = <-results:
+		case <-cancel:
+			return
+		}
+		if !ok {
+			break
+		}
+		scanned++
+		if total+len(hits) > grepMaxHits {
+			hits = hits[:grepMaxHits-total]
+		}
+		batch = append(batch, hits...)
+		total += len(hits)
+		if total >= grepMaxHits {
+			break
+		}
+		if time.Since(last) >= loadFlushTime {
+			if !send(batch, scanned, false) {
+				return
+			}
+			batch = nil
+			last = time.Now()
+		}
+	}
+	send(batch, scanned, true)
+}
+
+// grepFile ищет совпадения в одном файле; большие и двоичные файлы пропускаются
+func grepFile(root, rel string, re *regexp.Regexp) []grepHit {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() > grepMaxFileSize {
+		return nil
+	}
+	r := bufio.NewReaderSize(f, grepSniffSize)
+	head, _ := r.Peek(grepSniffSize)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+
+	var hits []grepHit
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), grepMaxLine)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Bytes()
+		if loc := re.FindIndex(line); loc != nil {
+			text, span := grepSnippet(line, loc)
+			hits = append(hits, grepHit{rel: rel, line: n, text: text, span: span})
+		}
+	}
+	return hits
+}
+
+// grepSnippet вырезает из строки кусок вокруг совпадения loc: на экран идёт одна
+// строка, а минифицированный код с тысячами совпадений целиком в памяти не держим.
+// Обрезанные края отмечаются многоточием, span — совпадение внутри куска.
+func grepSnippet(line []byte, loc []int) (string, [2]int) {
+	start := max(0, loc[0]-grepSnippetBefore)
+	end := min(len(line), start+grepSnippetSize)
+	// края не должны разрезать символ UTF-8
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	for end < len(line) && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	text := string(line[start:end])
+	span := [2]int{loc[0] - start, min(loc[1], end) - start}
+	if start > 0 {
+		text = "…" + text
+		span[0] += len("…")
+		span[1] += len("…")
+	}
+	if end < len(line) {
+		text += "…"
+	}
+	return text, span
+}
+
+func (g *grepSearch) apply(ev *grepEvent) {
+	g.hits = append(g.hits, ev.hits...)
+	g.files = ev.files
+	if ev.done {
+		g.running = false
+		g.truncated = len(g.hits) >= grepMaxHits
+	}
+}
+
+func (g *grepSearch) move(delta int) {
+	g.cursor += delta
+	if g.cursor >= len(g.hits) {
+		g.cursor = len(g.hits) - 1
+	}
+	if g.cursor < 0 {
+		g.cursor = 0
+	}
+	if g.cursor < g.offset {
+		g.offset = g.cursor
+	}
+	if g.cursor >= g.offset+listPopupRows {
+		g.offset = g.cursor - listPopupRows + 1
+	}
+}
+
+// selected возвращает полный путь и строку выбранного совпадения
+func (g *grepSearch) selected() (string, int, bool) {
+	if g.cursor < 0 || g.cursor >= len(g.hits) {
+		return "", 0, false
+	}
+	h := g.hits[g.cursor]
+	return filepath.Join(g.root, filepath.FromSlash(h.rel)), h.line, true
+}
+
+// grepKey обрабатывает перемещение по результатам; true — клавиша использована
+func grepKey(g *grepSearch, ev *tcell.EventKey) bool {
+	switch ev.Key() {
+	case tcell.KeyUp:
+		g.move(-1)
+	case tcell.KeyDown:
+		g.move(1)
+	case tcell.KeyPgUp:
+		g.move(-listPopupRows)
+	case tcell.KeyPgDn:
+		g.move(listPopupRows)
+	case tcell.KeyHome:
+		g.move(-len(g.hits))
+	case tcell.KeyEnd:
+		g.move(len(g.hits))
+	default:
+		return false
+	}
+	return true
+}
+
+// openEditor открывает файл в $VISUAL/$EDITOR на нужной строке, временно
+// отдавая ему терминал
+func openEditor(s tcell.Screen, path string, line int) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	if line > 0 {
+		args = append(args, "+"+strconv.Itoa(line))
+	}
+	args = append(args, path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	if err := s.Suspend(); err != nil {
+		return err
+	}
+	err := cmd.Run()
+	if rerr := s.Resume(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// ---------------- drawing ----------------
+func drawGrep(s tcell.Screen, g *grepSearch) {
+	sw, sh := s.Size()
+	w := sw * 7 / 8
+	if w < 40 {
+		w = 40
+	}
+	if w > sw {
+		w = sw
+	}
+	h := listPopupRows + 6
+	if h > sh {
+		h = sh
+	}
+	x := (sw - w) / 2
+	y := (sh - h) / 2
+
+	drawBox(s, x, y, w, h)
+	headerStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	grayStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	pathStyle := tcell.StyleDefault.Foreground(tcell.ColorBlue)
+
+	state := ""
+	if g.running {
+		state = " searching…"
+	} else if g.truncated {
+		state = " (limit reached)"
+	}
+	counter := fmt.Sprintf("%d hits, %d files%s", len(g.hits), g.files, state)
+	drawText(s, x+2, y+1, fmt.Sprintf("Search %q in %s", g.query, displayPath(g.root)), headerStyle, w-6-len([]rune(counter)))
+	drawText(s, x+w-2-len([]rune(counter)), y+1, counter, grayStyle, len([]rune(counter)))
+	drawText(s, x+2, y+h-2, "ENTER go to file  e edit  ESC close", grayStyle, w-4)
+
+	rows := h - 5
+	if len(g.hits) == 0 && !g.running {
+		drawText(s, x+2, y+3, "(no matches)", grayStyle, w-4)
+	}
+	for i := 0; i < rows && g.offset+i < len(g.hits); i++ {
+		hit := g.hits[g.offset+i]
+		selected := g.offset+i == g.cursor
+		prefix := fmt.Sprintf("%s:%d:", hit.rel, hit.line)
+
+		// отступ в начале строки не показываем, подсветку сдвигаем вместе с ним
+		text := strings.TrimLeft(hit.text, " \t")
+		cut := len(hit.text) - len(text)
+		marks := highlightRunes(text, [][2]int{{hit.span[0] - cut, hit.span[1] - cut}})
+
+		col := 0
+		put := func(r rune, st tcell.Style) {
+			if col >= w-4 {
+				return
+			}
+			if selected {
+				st = st.Reverse(true)
+			}
+			s.SetContent(x+2+col, y+3+i, r, nil, st)
+			col++
+		}
+		for _, r := range prefix {
+			put(r, pathStyle)
+		}
+		put(' ', textStyle)
+		j := 0
+		for _, r := range text {
+			st := textStyle
+			if r == '\t' {
+				r = ' '
+			}
+			if marks[j] {
+				st = st.Foreground(tcell.ColorYellow).Bold(true)
+			}
+			put(r, st)
+			j++
+		}
+		for col < w-4 {
+			put(' ', textStyle)
+		}
+	}
+}
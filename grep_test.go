@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGrepSnippet(t *testing.T) {
+	long := strings.Repeat("a", 1000) + "NEEDLE" + strings.Repeat("b", 1000)
+	euro := strings.Repeat("€", 100) + "NEEDLE" + strings.Repeat("€", 300)
+	tests := []struct {
+		name  string
+		line  string
+		match string
+		want  string // совпадение внутри куска; "" — кусок равен строке
+	}{
+		{"short line kept whole", "foo NEEDLE bar", "NEEDLE", ""},
+		{"long line cut on both sides", long, "NEEDLE", "NEEDLE"},
+		{"match at the start", "NEEDLE" + strings.Repeat("x", 1000), "NEEDLE", "NEEDLE"},
+		{"cut inside multibyte runes", euro, "NEEDLE", "NEEDLE"},
+		{"long match clipped", strings.Repeat("z", 1000), strings.Repeat("z", 1000), strings.Repeat("z", grepSnippetSize)},
+	}
+	for _, tt := range tests {
+		i := strings.Index(tt.line, tt.match)
+		text, span := grepSnippet([]byte(tt.line), []int{i, i + len(tt.match)})
+		if tt.want == "" {
+			if text != tt.line || span != [2]int{i, i + len(tt.match)} {
+				t.Errorf("%s: got %q %v, want the whole line", tt.name, text, span)
+			}
+			continue
+		}
+		if got := text[span[0]:span[1]]; got != tt.want {
+			t.Errorf("%s: span covers %q, want %q", tt.name, got, tt.want)
+		}
+		if len(text) > grepSnippetSize+2*len("…") {
+			t.Errorf("%s: snippet is %d bytes", tt.name, len(text))
+		}
+		if !utf8.ValidString(text) || !strings.Contains(tt.line, strings.Trim(text, "…")) {
+			t.Errorf("%s: snippet %q is not a part of the line", tt.name, text)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- hex preview ----------------
+// Двоичный файл показывается дампом: смещение, байты в hex и они же как
+// ASCII. В памяти держится только окно файла; при прокрутке за его края
+// нужное окно читается в фоне через ReadAt, так что листать можно файл
+// любого размера.
+const hexChunkBytes = 64 << 10
+
+// hexRowBytes — сколько байт помещается в строку дампа шириной w
+func hexRowBytes(w int) int {
+	if w >= 8+2+16*3+1+16 {
+		return 16
+	}
+	return 8
+}
+
+// startHexWindow читает в фоне окно файла с offset
+func startHexWindow(path string, offset int64, out chan<- tcell.Event) int {
+	previewGen++
+	gen := previewGen
+	go func() {
+		out <- &previewEvent{when: time.Now(), gen: gen, data: loadHexWindow(path, offset)}
+	}()
+	return gen
+}
+
+func loadHexWindow(path string, offset int64) *previewData {
+	d := &previewData{path: path, hex: true, offset: offset}
+	f, err := os.Open(path)
+	if err != nil {
+		d.note = err.Error()
+		return d
+	}
+	defer f.Close()
+	d.size = fileSize(f)
+	buf := make([]byte, hexChunkBytes)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		d.note = err.Error()
+		return d
+	}
+	d.chunk = buf[:n]
+	return d
+}
+
+// hexWindowFor проверяет, прочитаны ли байты [top, top+n); если нет,
+// возвращает начало окна, которое нужно прочитать
+func (d *previewData) hexWindowFor(top int64, n int) (int64, bool) {
+	end := min(top+int64(n), d.size)
+	if top >= d.offset && end <= d.offset+int64(len(d.chunk)) {
+		return 0, true
+	}
+	// окно берём с запасом назад, чтобы и прокрутка вверх не ждала чтения
+	return max(0, top-hexChunkBytes/4), false
+}
+
+// hexMaxTop — наибольшее смещение первой строки, при котором последняя
+// строка дампа ещё внизу экрана
+func hexMaxTop(size int64, rows, bpr int) int64 {
+	total := (size + int64(bpr) - 1) / int64(bpr)
+	return max(0, total-int64(rows)) * int64(bpr)
+}
+
+// parseOffset понимает 0x1f00, 4096, 16k и 50%
+func parseOffset(s string, size int64) (int64, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		return strconv.ParseInt(s[2:], 16, 64)
+	case strings.HasSuffix(s, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return 0, fmt.Errorf("bad percentage %q", s)
+		}
+		return int64(float64(size) * pct / 100), nil
+	}
+	return parseSize(s)
+}
+
+// drawHex рисует дамп, начиная со смещения top; байты вне окна показываются
+// пустыми, пока окно не дочитано
+func drawHex(s tcell.Screen, x, y, w, h int, d *previewData, top int64) {
+	grayStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	pos := fmt.Sprintf(" %08x / %s ", top, humanSize(d.size))
+	drawText(s, x+w-2-len(pos), y+h-1, pos, grayStyle, len(pos))
+
+	bpr := hexRowBytes(w - 2)
+	for row := 0; row < h-2; row++ {
+		off := top + int64(row*bpr)
+		if off >= d.size {
+			break
+		}
+		cy := y + 1 + row
+		drawText(s, x+1, cy, fmt.Sprintf("%08x", off), grayStyle, min(8, w-2))
+		for i := 0; i < bpr && off+int64(i) < d.size; i++ {
+			k := off + int64(i) - d.offset
+			if k < 0 || k >= int64(len(d.chunk)) {
+				continue
+			}
+			b := d.chunk[k]
+			// после восьмого байта — лишний пробел, как в hexdump -C
+			hx := x + 11 + i*3
+			if i >= 8 {
+				hx++
+			}
+			style := textStyle
+			if b == 0 {
+				style = grayStyle
+			}
+			if hx+2 < x+w-1 {
+				drawText(s, hx, cy, fmt.Sprintf("%02x", b), style, 2)
+			}
+			ch, chStyle := rune(b), textStyle
+			if b < 0x20 || b > 0x7e {
+				ch, chStyle = '.', grayStyle
+			}
+			if ax := x + 12 + bpr*3 + i; ax < x+w-1 {
+				s.SetContent(ax, cy, ch, nil, chStyle)
+			}
+		}
+	}
+}
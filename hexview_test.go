@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestParseOffset(t *testing.T) {
+	const size = 1000
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"4096", 4096, false},
+		{" 4096 ", 4096, false},
+		{"0x1f00", 0x1f00, false},
+		{"0X1F00", 0x1f00, false},
+		{"16k", 16 << 10, false},
+		{"1M", 1 << 20, false},
+		{"50%", 500, false},
+		{"0%", 0, false},
+		{"100%", 1000, false},
+		{"12.5%", 125, false},
+		{"", 0, true},
+		{"0x", 0, true},
+		{"0xzz", 0, true},
+		{"101%", 0, true},
+		{"-5%", 0, true},
+		{"%", 0, true},
+		{"-10", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseOffset(tt.in, size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseOffset(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseOffset(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHexMaxTop(t *testing.T) {
+	tests := []struct {
+		size      int64
+		rows, bpr int
+		want      int64
+	}{
+		{0, 10, 16, 0},
+		{100, 10, 16, 0},  // всё помещается на экран
+		{160, 10, 16, 0},  // ровно экран
+		{161, 10, 16, 16}, // лишняя неполная строка
+		{1600, 10, 16, 1440},
+		{1601, 10, 16, 1456},
+		{1600, 10, 8, 1520},
+		{1 << 40, 20, 16, 1<<40 - 320},
+	}
+	for _, tt := range tests {
+		if got := hexMaxTop(tt.size, tt.rows, tt.bpr); got != tt.want {
+			t.Errorf("hexMaxTop(%d, %d, %d) = %d, want %d", tt.size, tt.rows, tt.bpr, got, tt.want)
+		}
+	}
+}
+
+func TestHexWindowFor(t *testing.T) {
+	d := &previewData{hex: true, size: 1 << 20, offset: 4096, chunk: make([]byte, hexChunkBytes)}
+	tests := []struct {
+		top     int64
+		n       int
+		wantOff int64
+		wantOK  bool
+	}{
+		{4096, 320, 0, true},
+		{4096 + hexChunkBytes - 320, 320, 0, true},
+		{4096 + hexChunkBytes - 319, 320, 4096 + hexChunkBytes - 319 - hexChunkBytes/4, false},
+		{0, 320, 0, false},
+		{1 << 19, 320, 1<<19 - hexChunkBytes/4, false},
+	}
+	for _, tt := range tests {
+		off, ok := d.hexWindowFor(tt.top, tt.n)
+		if ok != tt.wantOK || (!ok && off != tt.wantOff) {
+			t.Errorf("hexWindowFor(%d, %d) = %d, %v, want %d, %v", tt.top, tt.n, off, ok, tt.wantOff, tt.wantOK)
+		}
+	}
+
+	// хвост файла короче экрана: достаточно прочитать до конца файла
+	tail := &previewData{hex: true, size: 5000, offset: 4096, chunk: make([]byte, 904)}
+	if _, ok := tail.hexWindowFor(4800, 320); !ok {
+		t.Errorf("hexWindowFor past the end of file wants a new window")
+	}
+}
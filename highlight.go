@@ -0,0 +1,270 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- syntax highlighting ----------------
+// Простая подсветка по словам: ключевые слова, строки, комментарии и числа.
+// Разбор идёт построчно, незакрытые блочный комментарий или многострочная
+// строка переносятся на следующие строки.
+type hlClass uint8
+
+const (
+	hlText hlClass = iota
+	hlKeyword
+	hlString
+	hlComment
+	hlNumber
+	// для разобранных форматов, см. structured.go
+	hlKey
+	hlHeading
+	hlBold
+	hlEmph
+	hlLink
+	hlError
+)
+
+var hlStyles = map[hlClass]tcell.Style{
+	hlText:    tcell.StyleDefault.Foreground(tcell.ColorWhite),
+	hlKeyword: tcell.StyleDefault.Foreground(tcell.ColorYellow),
+	hlString:  tcell.StyleDefault.Foreground(tcell.ColorGreen),
+	hlComment: tcell.StyleDefault.Foreground(tcell.ColorGray),
+	hlNumber:  tcell.StyleDefault.Foreground(tcell.ColorAqua),
+	hlKey:     tcell.StyleDefault.Foreground(tcell.ColorLightSkyBlue),
+	hlHeading: tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true),
+	hlBold:    tcell.StyleDefault.Foreground(tcell.ColorWhite).Bold(true),
+	hlEmph:    tcell.StyleDefault.Foreground(tcell.ColorWhite).Italic(true),
+	hlLink:    tcell.StyleDefault.Foreground(tcell.ColorLightSkyBlue).Underline(true),
+	hlError:   tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorMaroon),
+}
+
+type syntax struct {
+	name         string
+	keywords     map[string]bool
+	lineComment  []string
+	blockComment [2]string
+	quotes       string   // однострочные строки
+	longStrings  []string // строки, которые могут занимать несколько строк: `, """
+	rawLong      bool     // в длинных строках нет экранирования
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cKeywords = "auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while bool true false NULL #include #define #ifdef #ifndef #endif #if #else #pragma"
+	syntaxes  = []*syntax{
+		{name: "go", keywords: words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+			lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`, longStrings: []string{"`"}, rawLong: true},
+		{name: "c", keywords: words(cKeywords),
+			lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+		{name: "c++", keywords: words(cKeywords + " class namespace template typename public private protected virtual override new delete this using nullptr try catch throw auto constexpr"),
+			lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+		{name: "java", keywords: words("abstract boolean break byte case catch char class continue default do double else enum extends final finally float for if implements import instanceof int interface long new null package private protected public return short static super switch this throw throws try void while true false var val fun when object"),
+			lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+		{name: "javascript", keywords: words("async await break case catch class const continue default delete do else export extends finally for from function if import in instanceof let new null of return static super switch this throw try typeof undefined var void while yield true false interface type enum implements"),
+			lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`, longStrings: []string{"`"}},
+		{name: "rust", keywords: words("as async await break const continue crate else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+			lineComment: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"`},
+		{name: "python", keywords: words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self"),
+			lineComment: []string{"#"}, quotes: `"'`, longStrings: []string{`"""`, `'''`}},
+		{name: "ruby", keywords: words("alias and begin break case class def do else elsif end ensure false for if in module next nil not or redo rescue retry return self super then true undef unless until when while yield require"),
+			lineComment: []string{"#"}, quotes: `"'`},
+		{name: "shell", keywords: words("if then else elif fi case esac for while until do done in function return local export readonly set unset echo exit source"),
+			lineComment: []string{"#"}, quotes: `"'`},
+		{name: "lua", keywords: words("and break do else elseif end false for function goto if in local nil not or repeat return then true until while"),
+			lineComment: []string{"--"}, quotes: `"'`},
+		{name: "sql", keywords: words("select from where insert into values update set delete create table drop alter index join left right inner outer on group by order having limit and or not null as primary key distinct union SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER INDEX JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AND OR NOT NULL AS PRIMARY KEY DISTINCT UNION"),
+			lineComment: []string{"--"}, blockComment: [2]string{"/*", "*/"}, quotes: `'"`},
+		{name: "json", keywords: words("true false null"), quotes: `"`},
+		{name: "yaml", keywords: words("true false null yes no"), lineComment: []string{"#"}, quotes: `"'`},
+		{name: "toml", keywords: words("true false"), lineComment: []string{"#", ";"}, quotes: `"'`},
+		{name: "make", keywords: words("ifeq ifneq ifdef ifndef else endif include define endef export override"), lineComment: []string{"#"}},
+		{name: "dockerfile", keywords: words("FROM RUN CMD LABEL EXPOSE ENV ADD COPY ENTRYPOINT VOLUME USER WORKDIR ARG ONBUILD STOPSIGNAL HEALTHCHECK SHELL AS"),
+			lineComment: []string{"#"}, quotes: `"'`},
+		{name: "html", blockComment: [2]string{"<!--", "-->"}, quotes: `"'`},
+	}
+	syntaxByExt = map[string]string{
+		"go": "go", "c": "c", "h": "c", "cc": "c++", "cpp": "c++", "cxx": "c++", "hpp": "c++",
+		"java": "java", "kt": "java", "kts": "java", "scala": "java",
+		"js": "javascript", "mjs": "javascript", "cjs": "javascript", "jsx": "javascript", "ts": "javascript", "tsx": "javascript",
+		"rs": "rust", "py": "python", "pyw": "python", "rb": "ruby",
+		"sh": "shell", "bash": "shell", "zsh": "shell", "fish": "shell",
+		"lua": "lua", "sql": "sql", "json": "json", "yml": "yaml", "yaml": "yaml",
+		"toml": "toml", "ini": "toml", "cfg": "toml", "conf": "toml", "mk": "make",
+		"html": "html", "htm": "html", "xml": "html", "svg": "html",
+	}
+	syntaxByName = map[string]string{
+		"Makefile": "make", "GNUmakefile": "make", "Dockerfile": "dockerfile",
+		".bashrc": "shell", ".zshrc": "shell", ".profile": "shell", ".bash_profile": "shell",
+	}
+)
+
+func syntaxNamed(name string) *syntax {
+	for _, syn := range syntaxes {
+		if syn.name == name {
+			return syn
+		}
+	}
+	return nil
+}
+
+// detectSyntax выбирает язык по имени файла, расширению или строке #!
+func detectSyntax(path, firstLine string) *syntax {
+	base := filepath.Base(path)
+	if name, ok := syntaxByName[base]; ok {
+		return syntaxNamed(name)
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(base), "."))
+	if name, ok := syntaxByExt[ext]; ok {
+		return syntaxNamed(name)
+	}
+	if strings.HasPrefix(firstLine, "#!") {
+		switch {
+		case strings.Contains(firstLine, "python"):
+			return syntaxNamed("python")
+		case strings.Contains(firstLine, "node"):
+			return syntaxNamed("javascript")
+		case strings.Contains(firstLine, "ruby"):
+			return syntaxNamed("ruby")
+		case strings.Contains(firstLine, "lua"):
+			return syntaxNamed("lua")
+		case strings.Contains(firstLine, "sh"):
+			return syntaxNamed("shell")
+		}
+	}
+	return nil
+}
+
+func hasPrefixAt(r []rune, i int, prefix string) bool {
+	for _, c := range prefix {
+		if i >= len(r) || r[i] != c {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// highlightLines размечает каждую руну строк классом подсветки
+func highlightLines(lines []string, syn *syntax) [][]hlClass {
+	res := make([][]hlClass, len(lines))
+	inComment := false
+	longEnd := "" // конец незакрытой многострочной строки
+	for li, line := range lines {
+		r := []rune(line)
+		cls := make([]hlClass, len(r))
+		res[li] = cls
+		mark := func(from, to int, c hlClass) {
+			for k := from; k < to && k < len(cls); k++ {
+				cls[k] = c
+			}
+		}
+		// закрывает то, что тянется с прошлых строк, и возвращает позицию после него
+		closeAt := func(from int, end string, escapes bool) (int, bool) {
+			for k := from; k < len(r); k++ {
+				if escapes && r[k] == '\\' {
+					k++
+					continue
+				}
+				if hasPrefixAt(r, k, end) {
+					return k + len([]rune(end)), true
+				}
+			}
+			return len(r), false
+		}
+
+		i := 0
+		if inComment {
+			end, ok := closeAt(0, syn.blockComment[1], false)
+			mark(0, end, hlComment)
+			if !ok {
+				continue
+			}
+			inComment = false
+			i = end
+		} else if longEnd != "" {
+			end, ok := closeAt(0, longEnd, !syn.rawLong)
+			mark(0, end, hlString)
+			if !ok {
+				continue
+			}
+			longEnd = ""
+			i = end
+		}
+
+	scan:
+		for i < len(r) {
+			c := r[i]
+			for _, lc := range syn.lineComment {
+				// # считаем комментарием только в начале слова: $#, a#b — не он
+				if hasPrefixAt(r, i, lc) && (lc != "#" || i == 0 || unicode.IsSpace(r[i-1])) {
+					mark(i, len(r), hlComment)
+					break scan
+				}
+			}
+			if syn.blockComment[0] != "" && hasPrefixAt(r, i, syn.blockComment[0]) {
+				end, ok := closeAt(i+len([]rune(syn.blockComment[0])), syn.blockComment[1], false)
+				mark(i, end, hlComment)
+				inComment = !ok
+				i = end
+				continue
+			}
+			long := false
+			for _, ls := range syn.longStrings {
+				if hasPrefixAt(r, i, ls) {
+					end, ok := closeAt(i+len([]rune(ls)), ls, !syn.rawLong)
+					mark(i, end, hlString)
+					if !ok {
+						longEnd = ls
+					}
+					i = end
+					long = true
+					break
+				}
+			}
+			if long {
+				continue
+			}
+			switch {
+			case strings.ContainsRune(syn.quotes, c):
+				end, _ := closeAt(i+1, string(c), true)
+				mark(i, end, hlString)
+				i = end
+			case unicode.IsDigit(c) && (i == 0 || !isWordRune(r[i-1])):
+				j := i
+				for j < len(r) && (isWordRune(r[j]) || r[j] == '.') {
+					j++
+				}
+				mark(i, j, hlNumber)
+				i = j
+			case isWordRune(c) || c == '#':
+				j := i + 1
+				for j < len(r) && isWordRune(r[j]) {
+					j++
+				}
+				if syn.keywords[string(r[i:j])] {
+					mark(i, j, hlKeyword)
+				}
+				i = j
+			default:
+				i++
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectSyntax(t *testing.T) {
+	tests := []struct {
+		path, firstLine string
+		want            string // "" — язык не распознан
+	}{
+		{"main.go", "", "go"},
+		{"/src/lib/util.H", "", "c"},
+		{"app.tsx", "", "javascript"},
+		{"Makefile", "", "make"},
+		{"sub/Dockerfile", "", "dockerfile"},
+		{".bashrc", "", "shell"},
+		{"data.yml", "", "yaml"},
+		{"script", "#!/usr/bin/env python3", "python"},
+		{"script", "#!/usr/bin/node", "javascript"},
+		{"script", "#!/bin/bash", "shell"},
+		{"script", "#!/usr/bin/env lua", "lua"},
+		// расширение важнее строки #!
+		{"run.rb", "#!/bin/sh", "ruby"},
+		{"script", "# not a shebang: python", ""},
+		{"notes.txt", "", ""},
+		{"README", "", ""},
+	}
+	for _, tt := range tests {
+		syn := detectSyntax(tt.path, tt.firstLine)
+		got := ""
+		if syn != nil {
+			got = syn.name
+		}
+		if got != tt.want {
+			t.Errorf("detectSyntax(%q, %q) = %q, want %q", tt.path, tt.firstLine, got, tt.want)
+		}
+	}
+}
+
+// classString записывает разметку строки по символу на руну:
+// . текст, k ключевое слово, s строка, c комментарий, n число
+func classString(cls []hlClass) string {
+	const letters = ".kscn"
+	var b strings.Builder
+	for _, c := range cls {
+		b.WriteByte(letters[c])
+	}
+	return b.String()
+}
+
+func TestHighlightLines(t *testing.T) {
+	tests := []struct {
+		lang  string
+		lines []string
+		want  []string
+	}{
+		{"go", []string{
+			`func f() int { return 42 }`,
+		}, []string{
+			`kkkk...........kkkkkk.nn..`,
+		}},
+		{"go", []string{
+			`x := "a\"b" // c`,
+		}, []string{
+			`.....ssssss.cccc`,
+		}},
+		{"go", []string{
+			"s := `raw",
+			`\` + "`" + ` if`,
+		}, []string{
+			"....." + "ssss",
+			"ss.kk",
+		}},
+		{"go", []string{
+			`a /* b`,
+			`c */ if`,
+			`x1 1x`,
+		}, []string{
+			`..cccc`,
+			`cccc.kk`,
+			`...nn`,
+		}},
+		{"python", []string{
+			`def f(): """doc`,
+			`still""" # done`,
+			`x = "#" + y # c`,
+		}, []string{
+			`kkk......ssssss`,
+			`ssssssss.cccccc`,
+			`....sss.....ccc`,
+		}},
+		{"shell", []string{
+			`echo $# a#b`,
+			`if true; then`,
+		}, []string{
+			`kkkk.......`,
+			`kk.......kkkk`,
+		}},
+		{"c", []string{
+			`#include <x.h>`,
+			`int n = 0x1F; // 3.5`,
+		}, []string{
+			`kkkkkkkk......`,
+			`kkk.....nnnn..cccccc`,
+		}},
+		{"sql", []string{
+			`SELECT 'it''s' -- q`,
+		}, []string{
+			`kkkkkk.sssssss.cccc`,
+		}},
+		{"go", []string{
+			`s := "текст" // комментарий`,
+		}, []string{
+			`.....sssssss.cccccccccccccc`,
+		}},
+	}
+	for _, tt := range tests {
+		syn := syntaxNamed(tt.lang)
+		if syn == nil {
+			t.Fatalf("no syntax %q", tt.lang)
+		}
+		got := highlightLines(tt.lines, syn)
+		for i := range tt.lines {
+			if s := classString(got[i]); s != tt.want[i] {
+				t.Errorf("%s %q:\n got  %s\n want %s", tt.lang, tt.lines[i], s, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import "github.com/gdamore/tcell/v2"
+
+// ---------------- navigation history ----------------
+const maxHistory = 100
+
+// histEntry — посещённый каталог с положением курсора и прокрутки
+type histEntry struct {
+	path   string
+	name   string // элемент под курсором
+	offset int
+}
+
+// rememberPosition сохраняет положение курсора в текущую запись истории
+func rememberPosition(p *Panel) {
+	if len(p.history) == 0 {
+		p.history = []histEntry{{path: p.path}}
+		p.histPos = 0
+	}
+	h := &p.history[p.histPos]
+	h.path = p.path
+	h.name = ""
+	if e := selectedEntry(p); e != nil {
+		h.name = e.name
+	}
+	h.offset = p.offset
+}
+
+// pushHistory добавляет новый каталог, отбрасывая записи "вперёд"
+func pushHistory(p *Panel, path string) {
+	p.history = append(p.history[:p.histPos+1], histEntry{path: path})
+	if len(p.history) > maxHistory {
+		p.history = p.history[len(p.history)-maxHistory:]
+	}
+	p.histPos = len(p.history) - 1
+}
+
+// historyGo открывает запись истории idx, восстанавливая курсор и прокрутку
+func historyGo(p *Panel, idx int, out chan<- tcell.Event) bool {
+	if idx < 0 || idx >= len(p.history) || idx == p.histPos {
+		return false
+	}
+	rememberPosition(p)
+	h := p.history[idx]
+	startLoad(p, h.path, h.name, out)
+	p.loading.histPos = idx
+	p.loading.offset = h.offset
+	return true
+}
+
+// historyPopup показывает историю панели, последние записи сверху
+func historyPopup(p *Panel) *listPopup {
+	lp := &listPopup{title: "History (Enter - go, ESC - close)"}
+	for i := len(p.history) - 1; i >= 0; i-- {
+		mark := "  "
+		if i == p.histPos {
+			mark = "• "
+		}
+		lp.items = append(lp.items, mark+displayPath(p.history[i].path))
+	}
+	if len(p.history) > 0 {
+		lp.move(len(p.history) - 1 - p.histPos)
+	}
+	return lp
+}
+
+// historyIndex переводит строку всплывающего списка в индекс записи истории
+func historyIndex(p *Panel, row int) int {
+	return len(p.history) - 1 - row
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ---------------- ignore files ----------------
+// Поддерживается основное из синтаксиса .gitignore: шаблоны с * ? [..] и **,
+// якорение через '/', правила только для каталогов ("dir/") и отрицание "!".
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	byPath  bool // сравнивать с путём от каталога правила, а не с именем
+}
+
+// ignoreRules хранит правила по относительному пути каталога, где лежит файл
+type ignoreRules map[string][]ignoreRule
+
+// load читает файлы игнорирования каталога rel (относительно root)
+func (ir ignoreRules) load(root, rel string) {
+	var rules []ignoreRule
+	for _, name := range ignoreFileNames {
+		f, err := os.Open(filepath.Join(root, rel, name))
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if r, ok := parseIgnoreLine(sc.Text()); ok {
+				rules = append(rules, r)
+			}
+		}
+		f.Close()
+	}
+	if len(rules) > 0 {
+		ir[rel] = rules
+	}
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.byPath = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	re, err := regexp.Compile("^" + ignoreGlobToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+func ignoreGlobToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "/**/"):
+			// a/**/b совпадает и с a/b
+			b.WriteString("/(.*/)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**"):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored проверяет путь rel (через '/') по правилам всех каталогов-предков;
+// правила вложенных каталогов идут позже и перекрывают внешние, последнее
+// совпавшее правило побеждает, как в git
+func (ir ignoreRules) ignored(rel string, isDir bool) bool {
+	if len(ir) == 0 {
+		return false
+	}
+	var dirs []string
+	for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+		dirs = append(dirs, d)
+	}
+	dirs = append(dirs, ".")
+	result := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		sub := rel
+		if d != "." {
+			sub = strings.TrimPrefix(rel, d+"/")
+		}
+		for _, r := range ir[d] {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			target := path.Base(sub)
+			if r.byPath {
+				target = sub
+			}
+			if r.re.MatchString(target) {
+				result = !r.negate
+			}
+		}
+	}
+	return result
+}
+
+// walkTree обходит дерево под root, пропуская .git, скрытое (если не hidden)
+// и всё, что исключено файлами игнорирования. visit получает путь от корня
+// через '/'; false из visit или закрытие cancel прекращает обход.
+func walkTree(root string, hidden bool, cancel <-chan struct{}, visit func(rel string, d fs.DirEntry) bool) {
+	rules := ignoreRules{}
+	rules.load(root, ".")
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if p == root {
+			return nil
+		}
+		if err != nil || d == nil {
+			// недоступный каталог просто пропускаем
+			return nil
+		}
+		select {
+		case <-cancel:
+			return filepath.SkipAll
+		default:
+		}
+		name := d.Name()
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if (!hidden && isHiddenName(name)) || (d.IsDir() && name == ".git") || rules.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			rules.load(root, rel)
+		}
+		if !visit(rel, d) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
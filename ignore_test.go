@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		ok      bool
+		negate  bool
+		dirOnly bool
+		byPath  bool
+		match   []string
+		noMatch []string
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# comment", ok: false},
+		{line: "*.log", ok: true, match: []string{"a.log", ".log"}, noMatch: []string{"a.log.1", "a/b.log"}},
+		{line: "*.log  \r", ok: true, match: []string{"a.log"}},
+		{line: "!keep.log", ok: true, negate: true, match: []string{"keep.log"}},
+		{line: `\#name`, ok: true, match: []string{"#name"}},
+		{line: "build/", ok: true, dirOnly: true, match: []string{"build"}, noMatch: []string{"builds"}},
+		{line: "/root.txt", ok: true, byPath: true, match: []string{"root.txt"}, noMatch: []string{"a/root.txt"}},
+		{line: "doc/*.md", ok: true, byPath: true, match: []string{"doc/a.md"}, noMatch: []string{"doc/x/a.md", "a.md"}},
+		{line: "**/tmp", ok: true, byPath: true, match: []string{"tmp", "a/b/tmp"}, noMatch: []string{"a/tmpx"}},
+		{line: "out/**", ok: true, byPath: true, match: []string{"out/a", "out/a/b"}, noMatch: []string{"out", "outx/a"}},
+		{line: "a/**/b", ok: true, byPath: true, match: []string{"a/b", "a/x/y/b"}, noMatch: []string{"a/xb"}},
+		{line: "file?.txt", ok: true, match: []string{"file1.txt"}, noMatch: []string{"file.txt", "file12.txt"}},
+		{line: "[abc].go", ok: true, match: []string{"a.go", "c.go"}, noMatch: []string{"d.go"}},
+		{line: "[!abc].go", ok: true, match: []string{"d.go"}, noMatch: []string{"a.go"}},
+		{line: "[unclosed", ok: true, match: []string{"[unclosed"}},
+		{line: "a+b(1).txt", ok: true, match: []string{"a+b(1).txt"}, noMatch: []string{"aab1.txt"}},
+	}
+	for _, tt := range tests {
+		r, ok := parseIgnoreLine(tt.line)
+		if ok != tt.ok {
+			t.Errorf("parseIgnoreLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if r.negate != tt.negate || r.dirOnly != tt.dirOnly || r.byPath != tt.byPath {
+			t.Errorf("parseIgnoreLine(%q) = negate %v dirOnly %v byPath %v, want %v %v %v",
+				tt.line, r.negate, r.dirOnly, r.byPath, tt.negate, tt.dirOnly, tt.byPath)
+		}
+		for _, s := range tt.match {
+			if !r.re.MatchString(s) {
+				t.Errorf("parseIgnoreLine(%q) does not match %q", tt.line, s)
+			}
+		}
+		for _, s := range tt.noMatch {
+			if r.re.MatchString(s) {
+				t.Errorf("parseIgnoreLine(%q) matches %q", tt.line, s)
+			}
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	rules := ignoreRules{}
+	add := func(dir string, lines ...string) {
+		for _, l := range lines {
+			r, ok := parseIgnoreLine(l)
+			if !ok {
+				t.Fatalf("parseIgnoreLine(%q) failed", l)
+			}
+			rules[dir] = append(rules[dir], r)
+		}
+	}
+	add(".", "*.log", "build/", "/top.txt")
+	add("sub", "!keep.log", "local/*.tmp")
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"x/a.log", false, true},
+		{"sub/keep.log", false, false},
+		{"sub/other.log", false, true},
+		{"build", true, true},
+		{"build", false, false},
+		{"x/build", true, true},
+		{"top.txt", false, true},
+		{"x/top.txt", false, false},
+		{"sub/local/a.tmp", false, true},
+		{"local/a.tmp", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- image preview ----------------
+// Картинки, которые умеет декодировать стандартная библиотека, показываются
+// полублоками: в каждой клетке '▀' с цветом верхнего пикселя и фоном
+// нижнего. Декодирование и уменьшение идут в фоне вместе с остальным
+// просмотром; в памяти остаётся только уменьшенная копия.
+const (
+	imageMaxPixels = 50_000_000 // больше не декодируем — слишком много памяти
+	imageThumbSize = 800        // сторона уменьшенной копии
+)
+
+type imageInfo struct {
+	format string
+	w, h   int         // размеры оригинала
+	thumb  *image.RGBA // уменьшенная копия, прозрачность наложена на чёрный
+
+	// последняя раскладка полублоками: пересчитывается только при смене размера
+	cellsW, cellsH int
+	cells          *image.RGBA
+
+	// вывод пикселями, см. graphics.go
+	id       int    // номер картинки в терминале kitty
+	sixel    []byte // последний закодированный sixel
+	sixelKey string // для какого размера он закодирован
+}
+
+// loadImage пробует прочитать файл как картинку; head — его начало.
+// nil, nil — это не картинка
+func loadImage(path string, head []byte) (*imageInfo, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil && !errors.Is(err, image.ErrFormat) && len(head) >= previewMaxBytes {
+		// формат узнан, но заголовок не поместился в начало файла — например,
+		// у JPEG с большим блоком EXIF; читаем его из самого файла
+		if f, ferr := os.Open(path); ferr == nil {
+			cfg, format, err = image.DecodeConfig(bufio.NewReader(f))
+			f.Close()
+		}
+	}
+	if err != nil {
+		return nil, nil
+	}
+	if cfg.Width*cfg.Height > imageMaxPixels {
+		return nil, fmt.Errorf("(%s %d×%d, too large to preview)", format, cfg.Width, cfg.Height)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	tw, th := fitSize(cfg.Width, cfg.Height, imageThumbSize, imageThumbSize, false)
+	return &imageInfo{format: format, w: cfg.Width, h: cfg.Height, thumb: resizeImage(img, tw, th)}, nil
+}
+
+// fitSize вписывает w×h в maxW×maxH с сохранением пропорций; без grow
+// маленькая картинка не увеличивается
+func fitSize(w, h, maxW, maxH int, grow bool) (int, int) {
+	if w <= 0 || h <= 0 || maxW <= 0 || maxH <= 0 {
+		return 0, 0
+	}
+	if !grow && w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
+
+// resizeImage масштабирует усреднением по области: при уменьшении
+// в пиксель попадает среднее его прямоугольника, при увеличении — ближайший
+func resizeImage(src image.Image, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*sh/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*sh/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*sw/w
+			x1 := max(x0+1, b.Min.X+(x+1)*sw/w)
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// прозрачность накладываем на чёрный фон: цвета уже умножены на альфу
+					cr, cg, cb, _ := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(bl / n >> 8), 0xff})
+		}
+	}
+	return dst
+}
+
+// imageCells — место картинки в области w×h клеток: левый верхний угол
+// и размер в клетках, по центру с сохранением пропорций (клетка — два
+// пикселя в высоту)
+func imageCells(img *imageInfo, w, h int) (int, int, int, int) {
+	pw, ph := fitSize(img.w, img.h, w, h*2, true)
+	cw, ch := pw, (ph+1)/2
+	return (w - cw) / 2, (h - ch) / 2, cw, ch
+}
+
+// drawImage рисует картинку полублоками в области x, y, w, h
+func drawImage(s tcell.Screen, x, y, w, h int, img *imageInfo) {
+	ox, oy, cw, ch := imageCells(img, w, h)
+	if cw == 0 || ch == 0 {
+		return
+	}
+	if img.cells == nil || img.cellsW != cw || img.cellsH != ch {
+		img.cellsW, img.cellsH = cw, ch
+		img.cells = resizeImage(img.thumb, cw, ch*2)
+	}
+	rgb := func(c color.RGBA) tcell.Color {
+		return tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B))
+	}
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			top := img.cells.RGBAAt(cx, cy*2)
+			bottom := img.cells.RGBAAt(cx, cy*2+1)
+			style := tcell.StyleDefault.Foreground(rgb(top)).Background(rgb(bottom))
+			s.SetContent(x+ox+cx, y+oy+cy, '▀', nil, style)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH int
+		grow             bool
+		wantW, wantH     int
+	}{
+		{100, 50, 200, 200, false, 100, 50},
+		{100, 50, 200, 200, true, 200, 100},
+		{400, 200, 100, 100, false, 100, 50},
+		{200, 400, 100, 100, false, 50, 100},
+		{1000, 1, 100, 100, false, 100, 1},
+		{0, 10, 100, 100, false, 0, 0},
+		{10, 10, 0, 100, false, 0, 0},
+	}
+	for _, tt := range tests {
+		w, h := fitSize(tt.w, tt.h, tt.maxW, tt.maxH, tt.grow)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitSize(%d, %d, %d, %d, %v) = %d×%d, want %d×%d",
+				tt.w, tt.h, tt.maxW, tt.maxH, tt.grow, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+// readHead читает начало файла так же, как loadPreview
+func readHead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data[:min(len(data), previewMaxBytes)]
+}
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+	src := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	src.Set(1, 1, color.RGBA{R: 0xff, A: 0xff})
+
+	var pngData, jpegData bytes.Buffer
+	if err := png.Encode(&pngData, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	// JPEG, у которого перед размерами идёт блок APP1 наибольшей длины:
+	// вместе с SOI он длиннее прочитанного начала файла
+	app1 := make([]byte, 2+0xffff)
+	app1[0], app1[1], app1[2], app1[3] = 0xff, 0xe1, 0xff, 0xff
+	exif := append([]byte{0xff, 0xd8}, app1...)
+	exif = append(exif, jpegData.Bytes()[2:]...)
+
+	files := map[string][]byte{
+		"a.png":    pngData.Bytes(),
+		"b.jpg":    jpegData.Bytes(),
+		"exif.jpg": exif,
+		"text.txt": []byte("not an image"),
+		"bad.png":  pngData.Bytes()[:20],
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		format string // "" — не картинка
+	}{
+		{"a.png", "png"},
+		{"b.jpg", "jpeg"},
+		{"exif.jpg", "jpeg"},
+		{"text.txt", ""},
+		{"bad.png", ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		img, err := loadImage(path, readHead(t, path))
+		if err != nil {
+			t.Errorf("loadImage(%s) error: %v", tt.name, err)
+			continue
+		}
+		if tt.format == "" {
+			if img != nil {
+				t.Errorf("loadImage(%s) = %s image, want none", tt.name, img.format)
+			}
+			continue
+		}
+		if img == nil {
+			t.Errorf("loadImage(%s) = nil, want %s", tt.name, tt.format)
+			continue
+		}
+		if img.format != tt.format || img.w != 40 || img.h != 30 {
+			t.Errorf("loadImage(%s) = %s %d×%d, want %s 40×30", tt.name, img.format, img.w, img.h, tt.format)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -15,12 +20,33 @@ import (
 
 type Panel struct {
 	x, y, w, h int
-	items      []string
+	items      []Entry // видимые элементы; при фильтре — подмножество all
+	all        []Entry
 	cursor     int
 	active     bool
 	border     bool
 	path       string
 	offset     int
+
+	loading *dirLoad // фоновое чтение каталога, nil если не идёт
+	loadGen int
+
+	detailed bool      // подробный вид с колонками из config.Columns
+	sort     sortOrder // порядок элементов, запоминается для каждого каталога
+
+	history []histEntry // посещённые каталоги, см. history.go
+	histPos int
+
+	filter *listFilter // nil — фильтра нет
+	query  *findQuery  // не nil — в панели результаты поиска под path
+
+	noHistory bool // служебная панель (колонка родителя): без истории и учёта посещений
+
+	tree *treeState // не nil — режим дерева, см. tree.go
+
+	// у вкладки своя левая панель раскладки dual и запомненный фокус, см. tabs.go
+	left      *Panel
+	leftFocus bool
 }
 
 var (
@@ -31,6 +57,8 @@ var (
 	copySrc   string
 	moveReady = false
 	copyReady = false
+
+	diffSrc string // первый файл для сравнения
 )
 
 // ---------------- bookmarks ----------------
@@ -104,33 +132,41 @@ func drawPanel(s tcell.Screen, p *Panel) {
 
 	maxItems := visibleCount(p)
 
+	// текст в пределах панели: оставляем 1 символ отступа слева и справа
+	maxChars := p.w - 2
+	if maxChars < 0 {
+		maxChars = 0
+	}
+
+	nameW, cols := layoutColumns(config.Columns, maxChars)
+	if p.detailed && p.border {
+		// заголовки колонок пишем прямо в верхнюю рамку
+		drawText(s, p.x+1, p.y, detailedHeader(nameW, cols), borderStyle, maxChars)
+	}
+
 	for i := 0; i < maxItems && i+p.offset < len(p.items); i++ {
 		idx := i + p.offset
-		rawName := p.items[idx]
+		e := &p.items[idx]
 
-		display := rawName
-		if p.path == "" {
-			if rawName == "Home" {
-				display = "Home"
-			} else {
-				display = filepath.Base(rawName)
-			}
+		display := e.name
+		if p.path == "" && e.name != "Home" {
+			display = baseName(e.name)
 		}
-
-		isDir := false
-		var fullPath string
-		if p.path == "" {
-			if rawName == "Home" {
-				fullPath = homeDir
-			} else {
-				fullPath = rawName
+		// в дереве перед именем узла идут направляющие; фильтр действует
+		// только на верхний уровень
+		marks := highlightRunes(e.name, p.filter.spans(e.name))
+		shift := 0
+		if p.tree != nil {
+			display = e.tree + filepath.Base(e.name)
+			shift = len([]rune(e.tree))
+			if treeDepth(e.name) > 1 {
+				marks = nil
 			}
-		} else {
-			fullPath = filepath.Join(p.path, rawName)
 		}
-		if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
-			isDir = true
+		if p.detailed {
+			display = detailedLine(e, display, nameW, cols)
 		}
+		isDir := e.isDir()
 
 		// Если панель активна — используем старую логику (директории белым, файлы серым);
 		// если неактивна — делаем всю панель "серой" по цвету текста.
@@ -154,14 +190,15 @@ func drawPanel(s tcell.Screen, p *Panel) {
 			yOffset = i + 1
 		}
 
-		// текст в пределах панели: оставляем 1 символ отступа слева и справа
-		maxChars := p.w - 2
-		if maxChars < 0 {
-			maxChars = 0
-		}
+		// совпадения с фильтром подсвечиваем в имени (оно в начале строки,
+		// в дереве — после направляющих)
 		runes := []rune(display)
 		for j := 0; j < maxChars && j < len(runes); j++ {
-			s.SetContent(p.x+1+j, p.y+yOffset, runes[j], nil, styleLine)
+			style := styleLine
+			if marks[j-shift] && p.active {
+				style = style.Foreground(tcell.ColorYellow).Bold(true)
+			}
+			s.SetContent(p.x+1+j, p.y+yOffset, runes[j], nil, style)
 		}
 	}
 
@@ -197,87 +234,40 @@ func humanSize(size int64) string {
 	return fmt.Sprintf("%.0f%s", value, suffix)
 }
 
-func loadDir(path string) ([]string, error) {
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return nil, err
+// checkName проверяет введённое имя элемента: с разделителем пути или
+// именем "." и ".." файл оказался бы за пределами своего каталога
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return fmt.Errorf("invalid name %q", name)
 	}
+	return nil
+}
 
-	var hiddenDirs, dirs, files, hiddenFiles []string
+func isHiddenName(name string) bool {
+	return len(name) > 0 && name[0] == '.'
+}
 
+// visibleEntries отбирает элементы с учётом showHidden, не меняя порядок
+func visibleEntries(entries []Entry) []Entry {
+	var res []Entry
 	for _, e := range entries {
-		name := e.Name()
-		isHidden := len(name) > 0 && name[0] == '.'
-		if e.IsDir() {
-			if isHidden {
-				hiddenDirs = append(hiddenDirs, name)
-			} else {
-				dirs = append(dirs, name)
-			}
-		} else {
-			if isHidden {
-				hiddenFiles = append(hiddenFiles, name)
-			} else {
-				files = append(files, name)
-			}
+		if showHidden || !isHiddenName(e.name) {
+			res = append(res, e)
 		}
 	}
-
-	sort.Strings(hiddenDirs)
-	sort.Strings(dirs)
-	sort.Strings(files)
-	sort.Strings(hiddenFiles)
-
-	if showHidden {
-		res := append([]string{}, hiddenDirs...)
-		res = append(res, dirs...)
-		res = append(res, files...)
-		res = append(res, hiddenFiles...)
-		return res, nil
-	}
-	res := append([]string{}, dirs...)
-	res = append(res, files...)
-	return res, nil
-
+	return res
 }
 
-func fileInfo(path string) string {
-	info, err := os.Stat(path)
-	if err != nil {
-		return "error"
-	}
-
-	mode := info.Mode().String()
-	modTime := info.ModTime().Format("2006-01-02 15:04")
-
-	if info.IsDir() {
-		entries, _ := os.ReadDir(path)
-		total := len(entries)
-		hidden := 0
-		for _, e := range entries {
-			if len(e.Name()) > 0 && e.Name()[0] == '.' {
-				hidden++
-			}
-		}
-		return fmt.Sprintf("%-12s%6d%6d%10s   %s", mode, total, hidden, "-", modTime)
-	}
-
-	sizeStr := humanSize(info.Size())
-	return fmt.Sprintf("%-12s%6d%6d%10s   %s", mode, 0, 0, sizeStr, modTime)
-
+// arrangeEntries отбирает видимые элементы и сортирует их в порядке o
+func arrangeEntries(entries []Entry, o sortOrder) []Entry {
+	res := visibleEntries(entries)
+	sortEntries(res, o)
+	return res
 }
 
 // ---------------- modal ----------------
-func drawModal(s tcell.Screen, text string) {
-	sw, sh := s.Size()
-	w := len(text) + 6
-	if w < 20 {
-		w = 20
-	}
-	h := 5
-	x := (sw - w) / 2
-	y := (sh - h) / 2
-
+// drawBox очищает прямоугольник и рисует вокруг него рамку
+func drawBox(s tcell.Screen, x, y, w, h int) {
 	bg := tcell.ColorDefault
 	borderStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(bg)
 	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(bg)
@@ -299,11 +289,76 @@ func drawModal(s tcell.Screen, text string) {
 	s.SetContent(x+w-1, y, '┐', nil, borderStyle)
 	s.SetContent(x, y+h-1, '└', nil, borderStyle)
 	s.SetContent(x+w-1, y+h-1, '┘', nil, borderStyle)
+}
+
+func drawModal(s tcell.Screen, text string) {
+	sw, sh := s.Size()
+	w := len(text) + 6
+	if w < 20 {
+		w = 20
+	}
+	h := 5
+	x := (sw - w) / 2
+	y := (sh - h) / 2
 
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorDefault)
+	drawBox(s, x, y, w, h)
 	drawText(s, x+3, y+2, text, textStyle, w-6)
 
 }
 
+// drawPrompt отрисовывает окно ввода строки с курсором в конце;
+// под строкой показываются варианты дополнения, выбранный — инверсией
+func drawPrompt(s tcell.Screen, label, input string, cands []string, sel int) {
+	sw, sh := s.Size()
+	w := sw * 2 / 3
+	if w < 40 {
+		w = 40
+	}
+	if w > sw {
+		w = sw
+	}
+	const maxCands = 10
+	shown := len(cands)
+	if shown > maxCands {
+		shown = maxCands
+	}
+	h := 6
+	if shown > 0 {
+		h += shown + 1
+	}
+	x := (sw - w) / 2
+	y := (sh - h) / 2
+
+	labelStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	drawBox(s, x, y, w, h)
+	drawText(s, x+2, y+1, label, labelStyle, w-4)
+
+	// длинный ввод прокручиваем так, чтобы был виден его конец
+	runes := []rune(input)
+	maxChars := w - 5
+	if len(runes) > maxChars {
+		runes = runes[len(runes)-maxChars:]
+	}
+	drawText(s, x+2, y+3, string(runes), textStyle, w-4)
+	s.SetContent(x+2+len(runes), y+3, ' ', nil, textStyle.Reverse(true))
+
+	// список прокручиваем так, чтобы выбранный вариант был виден
+	first := 0
+	if sel >= maxCands {
+		first = sel - maxCands + 1
+	}
+	candStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	for i := 0; i < shown; i++ {
+		style := candStyle
+		if first+i == sel {
+			style = textStyle.Reverse(true)
+		}
+		drawText(s, x+2, y+5+i, cands[first+i], style, w-4)
+	}
+}
+
 // drawHelpPopup отрисовывает всплывающее окно с подсказками
 func drawHelpPopup(s tcell.Screen) {
 	helpText := []string{
@@ -311,62 +366,91 @@ func drawHelpPopup(s tcell.Screen) {
 		"",
 		"TAB    - Switch between panels",
 		"↑ / ↓  - Move cursor",
-		"→ / ↵  - Enter directory / open file",
+		"→ / ↵  - Enter directory / view file",
 		"←      - Go to parent directory",
+		"[ / ]  - History back / forward",
+		"h      - History list",
+		"g      - Go to path",
+		"j      - Jump to a frequent directory",
+		"/      - Filter (Tab mode, ^P pin)",
+		"f      - Find files (fuzzy)",
+		"F      - Search file contents",
+		"q      - Find by attributes",
+		"t / x  - New tab / close tab",
+		"{ / }  - Previous / next tab (Alt+1…9)",
+		"< / >  - Move tab left / right",
 		"a      - Add bookmark",
 		"d      - Delete bookmark",
 		"m      - Mark file/folder for move",
 		"c      - Mark file/folder for copy",
 		"p      - Paste (move/copy)",
+		"D      - Mark file for diff / compare",
 		".      - Toggle hidden files",
+		"v      - Toggle detailed view",
+		"T      - Tree view (→ expand, ← collapse)",
+		"E      - Expand tree to depth N",
+		"s      - Sort menu",
 		"r      - Refresh directory",
+		"R      - Rename file/folder",
+		"u      - Open WebDAV URL",
+		"w      - Share directory / stop sharing",
+		"F5 / C - Copy to… (other pane in dual)",
+		"F6 / M - Move to… (other pane in dual)",
+		"L      - Layout: sidebar / dual / miller",
+		"B      - Show / hide bookmarks",
+		"P      - Preview pane (sidebar layout)",
+		"W / N  - Preview: wrap / line numbers",
+		"PgUp/PgDn, Shift+↑/↓ - Scroll preview",
+		"O      - Jump to offset (hex preview)",
+		"z / Z  - Fold JSON node / fold all",
 		"DEL    - Delete file/folder",
-		"ESC    - Exit",
+		"ESC    - Cancel copy / download, exit",
 		"",
 		"Press any key to close",
 	}
 
 	sw, sh := s.Size()
-	w := 40
-	h := len(helpText) + 2
+	const colW = 42
+	// клавиши между заголовком и последней строкой; если в высоту экрана
+	// не помещаются, раскладываем их в две колонки
+	keys := helpText[2 : len(helpText)-2]
+	footer := helpText[len(helpText)-1]
+	rows := len(keys)
+	cols := 1
+	if rows+6 > sh {
+		cols = 2
+		rows = (rows + 1) / 2
+	}
+	w := colW*cols + 2
+	h := rows + 6
 	x := (sw - w) / 2
 	y := (sh - h) / 2
 
 	bg := tcell.ColorDefault
-	borderStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(bg)
 	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(bg)
 	headerStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(bg)
 
-	// Рисуем фон окна
-	for cy := y; cy < y+h; cy++ {
-		for cx := x; cx < x+w; cx++ {
-			s.SetContent(cx, cy, ' ', nil, textStyle)
-		}
-	}
+	// Рисуем фон окна и границы
+	drawBox(s, x, y, w, h)
 
-	// Рисуем границы
-	for cx := x; cx < x+w; cx++ {
-		s.SetContent(cx, y, '─', nil, borderStyle)
-		s.SetContent(cx, y+h-1, '─', nil, borderStyle)
-	}
-	for cy := y; cy < y+h; cy++ {
-		s.SetContent(x, cy, '│', nil, borderStyle)
-		s.SetContent(x+w-1, cy, '│', nil, borderStyle)
+	// Рисуем текст помощи
+	drawText(s, x+2, y+1, helpText[0], headerStyle, w-4)
+	for i, line := range keys {
+		drawText(s, x+2+colW*(i/rows), y+3+i%rows, line, textStyle, colW-2)
 	}
-	s.SetContent(x, y, '┌', nil, borderStyle)
-	s.SetContent(x+w-1, y, '┐', nil, borderStyle)
-	s.SetContent(x, y+h-1, '└', nil, borderStyle)
-	s.SetContent(x+w-1, y+h-1, '┘', nil, borderStyle)
+	drawText(s, x+2, y+h-2, footer, textStyle, w-4)
+}
 
-	// Рисуем текст помощи
-	for i, line := range helpText {
-		if i == 0 {
-			// Заголовок
-			drawText(s, x+2, y+1+i, line, headerStyle, w-4)
-		} else {
-			drawText(s, x+2, y+1+i, line, textStyle, w-4)
+// selectName ставит курсор на элемент с указанным именем
+func selectName(p *Panel, name string) bool {
+	for i, item := range p.items {
+		if item.name == name {
+			p.cursor = i
+			ensureCursorBounds(p)
+			return true
 		}
 	}
+	return false
 }
 
 func ensureCursorBounds(p *Panel) {
@@ -399,9 +483,28 @@ func ensureCursorBounds(p *Panel) {
 func main() {
 	const modalDuration = 750 * time.Millisecond // время показа уведомлений
 
+	importFrom := flag.String("import", "", "import visited directories from `zoxide` or autojump and exit")
+	importFile := flag.String("import-file", "", "autojump database to import (default: its standard location)")
+	flag.Parse()
+
 	hd, _ := os.UserHomeDir()
 	homeDir = hd
+	config = loadConfig()
+	setupCollation()
+	loadSorts()
+	loadFrecency()
+
+	if *importFrom != "" {
+		n, err := importFrecency(*importFrom, *importFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Imported %d directories\n", n)
+		return
+	}
 
+	// терминал спрашиваем о графике, пока он ещё не отдан tcell
+	imageProto := detectImageProtocol()
 	s, err := tcell.NewScreen()
 	if err != nil {
 		log.Fatal(err)
@@ -410,10 +513,15 @@ func main() {
 		log.Fatal(err)
 	}
 	defer s.Fini()
+	// незаконченные запросы WebDAV при выходе прерываем
+	defer davCancel()
 	s.Clear()
 
-	fileItems, _ := loadDir(homeDir)
-
+	// стартовый каталог можно передать аргументом, в том числе адрес WebDAV
+	startDir := homeDir
+	if flag.NArg() > 0 {
+		startDir = normalizePath(flag.Arg(0))
+	}
 	screenW, screenH := s.Size()
 	leftW := 24
 	rightX := leftW + 1
@@ -424,17 +532,92 @@ func main() {
 
 	sidebar := &Panel{
 		x: 0, y: 4, w: leftW, h: screenH - 4,
-		items:  bookmarks,
+		items:  bookmarkEntries(bookmarks),
 		active: true,
 		border: false,
 		path:   "",
 	}
 	filelist := &Panel{
 		x: rightX, y: 3, w: rightW, h: rightH,
-		items:  fileItems,
-		active: false,
-		border: true,
-		path:   homeDir,
+		active:   false,
+		border:   true,
+		path:     startDir,
+		detailed: config.DetailedView,
+		sort:     sortFor(startDir),
+	}
+
+	// вкладки прошлого запуска; каталог из аргумента открывается в новой вкладке.
+	// В раскладке dual вместо закладок слева второй список файлов, у каждой
+	// вкладки свой; filelist — тот из двух, что в фокусе
+	saved := loadTabs()
+	leftDir := func(dir string) string {
+		if dir != "" {
+			return dir
+		}
+		// в прежнем формате левая панель была одна на все вкладки
+		if saved.Left != "" {
+			return saved.Left
+		}
+		return startDir
+	}
+	var tabs []*Panel
+	var tabNames []string // на чём стоял курсор во вкладках
+	for _, t := range saved.Tabs {
+		tab := newTab(filelist, t.Path)
+		tab.left = newTab(filelist, leftDir(t.Left))
+		tabs = append(tabs, tab)
+		tabNames = append(tabNames, t.Name)
+	}
+	activeTab := saved.Active
+	if flag.NArg() > 0 || len(tabs) == 0 {
+		filelist.left = newTab(filelist, leftDir(""))
+		tabs = append(tabs, filelist)
+		tabNames = append(tabNames, "")
+		activeTab = len(tabs) - 1
+	}
+	filelist = tabs[activeTab]
+	pane := filelist.left
+	dual := config.Layout == "dual"
+	// в раскладке miller слева от списка — его родительский каталог, справа — просмотр
+	miller := config.Layout == "miller"
+	parentCol := &Panel{y: filelist.y, h: filelist.h, border: true, noHistory: true}
+	var preview *previewData
+	previewFor := "" // для какого пути запрошен просмотр
+	previewWant := 0 // поколение ожидаемого ответа
+	previewX, previewW := 0, 0
+	previewShown := false // в miller всегда, в sidebar — по config.Preview
+	gfx := newTermGraphics(imageProto)
+	previewStale := false // файл изменился, просмотр надо перечитать
+	var previewTop int64  // прокрутка просмотра: строка текста или смещение дампа
+	hexRequested := int64(-1)
+
+	// scrollPreview листает просмотр на n строк
+	scrollPreview := func(n int) {
+		d := preview
+		if d == nil {
+			return
+		}
+		rows := tabs[activeTab].h - 2
+		var limit int64
+		switch {
+		case d.hex:
+			bpr := hexRowBytes(previewW - 2)
+			previewTop += int64(n * bpr)
+			limit = hexMaxTop(d.size, rows, bpr)
+		case d.dir:
+			previewTop += int64(n)
+			limit = int64(max(0, len(d.entries)-rows))
+		case d.json != nil:
+			// в JSON двигается курсор узлов, а вид идёт за ним
+			d.cursor = max(0, min(d.cursor+n, len(d.lines)-1))
+			previewTop = min(previewTop, int64(d.cursor))
+			previewTop = max(previewTop, int64(d.cursor-rows+1))
+			return
+		default:
+			previewTop += int64(n)
+			limit = int64(max(0, len(d.lines)-rows))
+		}
+		previewTop = max(0, min(previewTop, limit))
 	}
 
 	ensureCursorBounds(sidebar)
@@ -443,13 +626,109 @@ func main() {
 	panels := []*Panel{sidebar, filelist}
 	current := 0
 
-	modalActive := false
-	modalText := ""
-	modalTimer := time.Time{}
+	// otherPane — список, который не в фокусе: туда по умолчанию копируем и переносим
+	otherPane := func() *Panel {
+		if filelist == pane {
+			return tabs[activeTab]
+		}
+		return pane
+	}
+	focusList := func(p *Panel) {
+		panels[current].active = false
+		filelist = p
+		panels[1] = p
+		current = 1
+		p.active = true
+	}
+	// sidebarShown — видны ли закладки; в dual их место занимает второй список
+	sidebarShown := func() bool {
+		return !dual && !config.HideSidebar
+	}
+	applyLayout := func() {
+		dual = config.Layout == "dual"
+		miller = config.Layout == "miller"
+		x0 := 0
+		if sidebarShown() {
+			x0 = rightX
+		}
+		w := screenW - x0 - 1
+		listX, listW := x0, w
+		switch {
+		case dual:
+			half := screenW / 2
+			for _, t := range tabs {
+				t.left.x, t.left.w = 0, half
+			}
+			listX, listW = half, screenW-half-1
+		case miller:
+			pw, cw, vw := millerWidths(w)
+			parentCol.x, parentCol.w = x0, pw
+			listX, listW = x0+pw, cw
+			previewX, previewW = x0+pw+cw, vw
+		case config.Preview:
+			listW = w / 2
+			previewX, previewW = x0+listW, w-listW
+		}
+		previewShown = miller || (!dual && config.Preview)
+		for _, t := range tabs {
+			t.x, t.w = listX, listW
+		}
+		if sidebarShown() && current == 0 {
+			return
+		}
+		if filelist == pane && !dual {
+			focusList(tabs[activeTab])
+		} else {
+			focusList(filelist)
+		}
+	}
+	applyLayout()
+
+	modalActive := configWarning != ""
+	modalText := configWarning
+	modalTimer := time.Now().Add(3 * modalDuration)
 	helpActive := false // Флаг для отображения помощи
+	sortMenuActive := false
+	filterEditing := false      // ввод фильтра: символы идут в filelist.filter
+	var histPopup *listPopup    // открытый список истории
+	var finderPopup *finder     // открытый поиск по дереву
+	var grepResults *grepSearch // результаты поиска по содержимому
+	var pgr *pager              // открытый просмотр файла
+	var dv *diffView            // открытое сравнение файлов
+	var op *fileOp              // идущее копирование, перенос или удаление
 
 	deleteIndex := -1
-	deleteFileIndex := -1
+	// удаляемый файл запоминаем по пути: пока ждём подтверждения, список
+	// может перечитаться и элементы сдвинутся
+	deletePath, deleteName := "", ""
+	var deletePanel *Panel
+
+	// строка ввода: по Enter вызывается promptDone с введённым текстом
+	promptActive := false
+	promptLabel := ""
+	promptInput := ""
+	var promptDone func(string)
+	// дополнение по Tab: promptComplete задаётся после openPrompt, если нужно
+	var promptComplete func(string) []string
+	promptNoPrefix := false // варианты не продолжают ввод, общий префикс не подставляем
+	var promptCands []string
+	promptCandIdx := -1
+	openPrompt := func(label, initial string, done func(string)) {
+		promptActive = true
+		promptLabel = label
+		promptInput = initial
+		promptDone = done
+		promptComplete = nil
+		promptNoPrefix = false
+		promptCands = nil
+	}
+
+	var share *shareServer
+	defer func() {
+		if share != nil {
+			share.stop()
+		}
+	}()
 
 	// канал для событий от tcell — читаем PollEvent в горутине и шлем событие сюда
 	events := make(chan tcell.Event, 16)
@@ -465,6 +744,127 @@ func main() {
 		}
 	}()
 
+	// изменения в каталоге списка приходят в тот же канал как *fsChangeEvent
+	watcher, err := newDirWatcher(events)
+	if err == nil {
+		defer watcher.close()
+	}
+
+	// каталоги читаются в фоне, порции приходят в канал как *dirLoadEvent
+	openDir := func(p *Panel, path, selectName string) {
+		startLoad(p, path, selectName, events)
+	}
+	for i, t := range tabs {
+		openDir(t, t.path, tabNames[i])
+	}
+	if dual {
+		openDir(pane, pane.path, "")
+	}
+	defer func() {
+		saveTabs(tabs, activeTab)
+	}()
+
+	// showTab делает текущей вкладку i вместе с её левой панелью и фокусом
+	showTab := func(i int) {
+		filelist.active = false
+		activeTab = i
+		pane = tabs[i].left
+		filelist = tabs[i]
+		if dual && filelist.leftFocus {
+			filelist = pane
+		}
+		panels[1] = filelist
+		filelist.active = current == 1
+		// изменения в неактивной вкладке не отслеживались — перечитываем
+		if tabs[i].loading == nil {
+			reloadPanel(tabs[i], events)
+		}
+		if dual && pane.loading == nil {
+			reloadPanel(pane, events)
+		}
+		saveTabs(tabs, activeTab)
+	}
+	switchTab := func(i int) {
+		if i < 0 || i >= len(tabs) || i == activeTab {
+			return
+		}
+		tabs[activeTab].leftFocus = filelist == pane
+		showTab(i)
+	}
+
+	// runOp выполняет операцию с файлами в фоне; done вызывается в этом цикле,
+	// когда она закончится (прерванную ESC операцию обрабатывает сам цикл)
+	runOp := func(text string, work func(ctx context.Context) error, done func(err error)) {
+		if op != nil {
+			modalText = fmt.Sprintf("Wait for: %s (ESC to cancel)", op.text)
+			modalActive = true
+			modalTimer = time.Now().Add(modalDuration)
+			return
+		}
+		op = startFileOp(text, work, done, events)
+	}
+
+	// transfer копирует или переносит элемент под курсором; каталог назначения
+	// по умолчанию — соседний список в раскладке dual, иначе текущий
+	transfer := func(move bool) {
+		e := selectedEntry(filelist)
+		if current != 1 || e == nil {
+			return
+		}
+		src := entryPath(filelist, e)
+		dest := filelist.path
+		if dual {
+			dest = otherPane().path
+		}
+		label, verb, done := "Copy to:", "Copy", "Copied"
+		if move {
+			label, verb, done = "Move to:", "Move", "Moved"
+		}
+		openPrompt(label, dest, func(input string) {
+			if strings.TrimSpace(input) == "" {
+				return
+			}
+			dst := resolvePath(input, filelist.path)
+			runOp(fmt.Sprintf("%s %s", verb, baseName(src)), func(ctx context.Context) error {
+				// в существующий каталог кладём под тем же именем
+				if info, err := statPath(ctx, dst); err == nil && info.IsDir() {
+					dst = joinPath(dst, baseName(src))
+				}
+				switch {
+				case dst == src:
+					return fmt.Errorf("source and destination are the same")
+				case move:
+					return renamePath(ctx, src, dst)
+				}
+				return copyPath(ctx, src, dst)
+			}, func(err error) {
+				if err != nil {
+					modalText = fmt.Sprintf("%s error: %v", verb, err)
+				} else {
+					modalText = fmt.Sprintf("%s to: %s", done, displayPath(parentPath(dst)))
+					reloadPanel(filelist, events)
+					if dual {
+						reloadPanel(otherPane(), events)
+					}
+				}
+				modalActive = true
+				modalTimer = time.Now().Add(modalDuration)
+			})
+		})
+	}
+
+	// openFile показывает текстовый файл во встроенном просмотре, остальные
+	// отдаёт xdg-open, когда чтение определит, что файл не текстовый
+	openFile := func(path string) {
+		pgr = openPager(path, config.TabWidth, events)
+	}
+
+	watchKey := "" // пути, за которыми сейчас следит watcher
+
+	// обновление по 'r': "Refreshed" показываем, когда чтение закончилось
+	var refreshPanel *Panel
+	refreshGen := 0
+
 	quit := false
 	for !quit {
 		// подготовка канала таймера (nil если таймер не нужен)
@@ -480,36 +880,240 @@ func main() {
 			}
 		}
 
+		// зависшее чтение (например, недоступный NFS) прерываем по таймауту в
+		// любой панели, не только в той, что в фокусе
+		loadPanels := []*Panel{parentCol}
+		for _, t := range tabs {
+			loadPanels = append(loadPanels, t, t.left)
+		}
+		var loadTimerChan <-chan time.Time
+		var stalled time.Time
+		for _, p := range loadPanels {
+			if ld := p.loading; ld != nil && (stalled.IsZero() || ld.progress.Before(stalled)) {
+				stalled = ld.progress
+			}
+		}
+		if !stalled.IsZero() {
+			loadTimerChan = time.After(time.Until(stalled.Add(loadTimeout)))
+		}
+
 		// --- отрисовка ---
 		s.Clear()
+		// поверх всплывающих окон картинку пикселями не выводим
+		overlay := modalActive || helpActive || sortMenuActive || promptActive ||
+			histPopup != nil || finderPopup != nil || grepResults != nil || pgr != nil || dv != nil
+		pixels := gfx.pixels() && !overlay
+
+		right := tabs[activeTab]
+		if miller {
+			// колонка родителя повторяет путь списка, курсор — на текущем каталоге
+			if parent := parentPath(right.path); isRootPath(right.path) || right.query != nil {
+				parentCol.path, parentCol.items, parentCol.all = "", nil, nil
+			} else if parentCol.path != parent && (parentCol.loading == nil || parentCol.loading.path != parent) {
+				openDir(parentCol, parent, baseName(right.path))
+			} else if parentCol.loading == nil {
+				selectName(parentCol, baseName(right.path))
+			}
+		}
+		if previewShown {
+			// просмотр элемента под курсором
+			target := ""
+			e := selectedEntry(right)
+			if e != nil {
+				target = entryPath(right, e)
+			}
+			if target != previewFor || previewStale {
+				// изменившийся файл перечитываем на той же позиции и без мигания
+				if target != previewFor {
+					preview = nil
+					previewTop = 0
+				}
+				previewFor = target
+				previewStale = false
+				hexRequested = -1
+				if e != nil {
+					previewWant = startPreview(target, e.isDir(), config.TabWidth, events)
+				}
+			}
+			// дамп за пределами прочитанного окна дочитываем
+			if d := preview; d != nil && d.hex && d.path == previewFor {
+				off, ok := d.hexWindowFor(previewTop, (right.h-2)*hexRowBytes(previewW-2))
+				if !ok && off != hexRequested {
+					hexRequested = off
+					previewWant = startHexWindow(previewFor, off, events)
+				}
+			}
+		}
 
-		addrStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
-		drawText(s, rightX+1, 1, fmt.Sprintf(" %s ", filelist.path), addrStyle, rightW-2)
+		tabX, tabW := right.x+1, right.w-2
+		if miller {
+			tabX, tabW = parentCol.x+1, parentCol.w+right.w-2
+		}
+		drawTabBar(s, tabX, 0, tabW, tabs, activeTab)
+
+		// адрес над списком, фильтр и порядок сортировки — на его нижней рамке
+		drawList := func(p *Panel, addrX, addrW int) {
+			addrStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+			if dual && !p.active {
+				addrStyle = tcell.StyleDefault.Foreground(tcell.ColorGray)
+			}
+			addr := fmt.Sprintf(" %s ", displayPath(p.path))
+			if p.query != nil {
+				addr += fmt.Sprintf("[find: %s] ", p.query.text)
+			}
+			drawText(s, addrX, 1, addr, addrStyle, addrW)
+			if ld := p.loading; ld != nil {
+				loadText := fmt.Sprintf(" loading… %d ", len(ld.entries))
+				drawText(s, addrX+addrW-len([]rune(loadText)), 1, loadText, addrStyle.Reverse(true), len([]rune(loadText)))
+			}
 
-		drawPanel(s, sidebar)
-		drawPanel(s, filelist)
+			drawPanel(s, p)
+			if f := p.filter; f != nil {
+				filterText := fmt.Sprintf(" %s ", f)
+				filterStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+				drawText(s, p.x+1, p.y+p.h-1, filterText, filterStyle, len([]rune(filterText)))
+				if filterEditing && p == filelist {
+					s.SetContent(p.x+1+len([]rune(filterText)), p.y+p.h-1, ' ', nil, filterStyle.Reverse(true))
+				}
+			}
+			sortText := fmt.Sprintf(" %s ", p.sort)
+			drawText(s, p.x+p.w-2-len([]rune(sortText)), p.y+p.h-1, sortText, tcell.StyleDefault.Foreground(tcell.ColorGray), len([]rune(sortText)))
+		}
+		if dual {
+			drawList(pane, pane.x+1, pane.w-2)
+		} else if sidebarShown() {
+			drawPanel(s, sidebar)
+		}
+		if miller {
+			// адрес — над колонками родителя и списка вместе
+			drawList(right, parentCol.x+1, parentCol.w+right.w-2)
+			drawParentColumn(s, parentCol)
+		} else {
+			drawList(right, right.x+1, right.w-2)
+		}
+		if previewShown {
+			drawPreview(s, previewX, right.y, previewW, right.h, preview, previewTop, pixels)
+		}
 
+		statusX, statusW := rightX+1, rightW-2
+		if !sidebarShown() {
+			statusX, statusW = 1, screenW-2
+		}
 		status := " Ready "
-		if current == 1 && len(filelist.items) > 0 && filelist.cursor >= 0 && filelist.cursor < len(filelist.items) {
-			name := filelist.items[filelist.cursor]
-			path := filepath.Join(filelist.path, name)
-			status = fileInfo(path)
+		if op != nil {
+			status = fmt.Sprintf(" %s… (ESC to cancel)", op.text)
+		} else if e := selectedEntry(filelist); current == 1 && e != nil {
+			status = entryInfo(e)
+			if d := preview; previewShown && d != nil && d.img != nil && d.path == previewFor {
+				status += fmt.Sprintf("  %s %d×%d", d.img.format, d.img.w, d.img.h)
+			}
 		}
 		statusStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
-		drawText(s, rightX+1, filelist.y+filelist.h+1, status, statusStyle, rightW-2)
+		drawText(s, statusX, filelist.y+filelist.h+1, status, statusStyle, statusW)
+		if share != nil {
+			mode := "read-only"
+			if share.writable {
+				mode = "read-write"
+			}
+			if share.public {
+				mode += ", network"
+			}
+			shareStyle := tcell.StyleDefault.Foreground(tcell.ColorGreen)
+			drawText(s, statusX, filelist.y+filelist.h+2, fmt.Sprintf(" Sharing %s (%s) — w or ESC to stop", share.url, mode), shareStyle, statusW)
+		}
+
+		// просмотр файла закрывает всё, кроме сообщений и строки ввода
+		if pgr != nil {
+			drawPager(s, pgr)
+		}
+		if dv != nil {
+			drawDiff(s, dv)
+		}
 
 		if modalActive {
 			drawModal(s, modalText)
 		}
 
+		if promptActive {
+			drawPrompt(s, promptLabel, promptInput, promptCands, promptCandIdx)
+		}
+
+		if sortMenuActive {
+			drawSortMenu(s, filelist.sort)
+		}
+
+		if histPopup != nil {
+			drawListPopup(s, histPopup)
+		}
+
+		if finderPopup != nil {
+			drawFinder(s, finderPopup)
+		}
+
+		if grepResults != nil {
+			drawGrep(s, grepResults)
+		}
+
 		// Отображаем помощь, если она активна
 		if helpActive {
 			drawHelpPopup(s)
 		}
 
 		s.Show()
+		if d := preview; pixels && previewShown && d != nil && d.img != nil && d.path == previewFor {
+			gfx.update(s, d.img, previewX+1, right.y+1, previewW-2, right.h-2)
+		} else {
+			gfx.update(s, nil, 0, 0, 0, 0)
+		}
 		// --- конец отрисовки ---
 
+		if watcher != nil {
+			target := ""
+			if e := selectedEntry(filelist); e != nil {
+				target = entryPath(filelist, e)
+			}
+			paths := []string{filelist.path, target}
+			if dual {
+				paths = append(paths, otherPane().path)
+			}
+			// наблюдение меняем, только когда сменились пути
+			if key := strings.Join(paths, "\x00"); key != watchKey {
+				watchKey = key
+				watcher.watch(paths...)
+			}
+		}
+
+		// число элементов подкаталогов считаем в фоне, один раз: для строки статуса —
+		// под курсором, для колонки count — все видимые
+		lists := []*Panel{filelist}
+		if dual {
+			lists = append(lists, otherPane())
+		}
+		for _, p := range lists {
+			treeRefresh(p, events)
+			if p.loading != nil {
+				continue
+			}
+			if e := selectedEntry(p); p == filelist && e != nil && e.isDir() && !e.counted && !e.counting {
+				e.counting = true
+				countDirAsync(p, p.path, e.name, events)
+			}
+			if wantsCount(p) {
+				for i := p.offset; i < len(p.items) && i < p.offset+visibleCount(p); i++ {
+					if e := &p.items[i]; e.isDir() && !e.counted && !e.counting {
+						e.counting = true
+						countDirAsync(p, p.path, e.name, events)
+					}
+				}
+			}
+		}
+
+		// растущий файл в просмотре проверяем периодически
+		var followChan <-chan time.Time
+		if pgr != nil && pgr.follow && !pgr.loading {
+			followChan = time.After(followInterval)
+		}
+
 		// ждём либо событие, либо таймер
 		select {
 		case ev, ok := <-events:
@@ -520,13 +1124,52 @@ func main() {
 			}
 			switch ev := ev.(type) {
 			case *tcell.EventKey:
+				if promptActive {
+					switch ev.Key() {
+					case tcell.KeyEscape:
+						promptActive = false
+					case tcell.KeyEnter:
+						promptActive = false
+						promptDone(promptInput)
+					case tcell.KeyTAB:
+						if promptComplete == nil {
+							break
+						}
+						// список уже показан — повторный Tab перебирает варианты
+						if len(promptCands) > 0 {
+							promptCandIdx = (promptCandIdx + 1) % len(promptCands)
+							promptInput = promptCands[promptCandIdx]
+							break
+						}
+						cands := promptComplete(promptInput)
+						if len(cands) == 1 {
+							promptInput = cands[0]
+						} else if len(cands) > 1 {
+							if p := commonPrefix(cands); !promptNoPrefix && strings.HasPrefix(p, promptInput) {
+								promptInput = p
+							}
+							promptCands = cands
+							promptCandIdx = -1
+						}
+					case tcell.KeyBackspace, tcell.KeyBackspace2:
+						if r := []rune(promptInput); len(r) > 0 {
+							promptInput = string(r[:len(r)-1])
+						}
+						promptCands = nil
+					case tcell.KeyRune:
+						promptInput += string(ev.Rune())
+						promptCands = nil
+					}
+					continue
+				}
+
 				// если модалка активна и у неё ZERO-таймер — это подтверждение (ожидаем y/n/esc)
 				if modalActive && modalTimer.IsZero() {
 					switch ev.Key() {
 					case tcell.KeyEscape:
 						modalActive = false
 						deleteIndex = -1
-						deleteFileIndex = -1
+						deletePath = ""
 					case tcell.KeyRune:
 						if ev.Rune() == 'y' {
 							if deleteIndex >= 0 && deleteIndex < len(sidebar.items) {
@@ -535,42 +1178,194 @@ func main() {
 								if sidebar.cursor >= len(sidebar.items) {
 									sidebar.cursor = len(sidebar.items) - 1
 								}
-								saveBookmarks(sidebar.items)
+								saveBookmarks(entryNames(sidebar.items))
 								deleteIndex = -1
 								modalActive = true
 								modalText = "Bookmark deleted"
 								modalTimer = time.Now().Add(modalDuration)
 								ensureCursorBounds(sidebar)
-							} else if deleteFileIndex >= 0 && deleteFileIndex < len(filelist.items) {
+							} else if deletePath != "" {
 								// удаление файла/директории
-								fullPath := filepath.Join(filelist.path, filelist.items[deleteFileIndex])
-								err := os.RemoveAll(fullPath)
-								if err != nil {
-									modalText = fmt.Sprintf("Delete error: %v", err)
-								} else {
-									modalText = fmt.Sprintf("Deleted: %s", filelist.items[deleteFileIndex])
-									if items, err := loadDir(filelist.path); err == nil {
-										filelist.items = items
-										filelist.cursor = 0
-										filelist.offset = 0
-										ensureCursorBounds(filelist)
+								p, fullPath, name := deletePanel, deletePath, deleteName
+								deletePath = ""
+								modalActive = false
+								runOp("Delete "+name, func(ctx context.Context) error {
+									// RemoveAll не сообщает об отсутствующем файле — проверяем сами
+									if _, err := os.Lstat(fullPath); os.IsNotExist(err) && !isRemote(fullPath) {
+										return fmt.Errorf("%s no longer exists, nothing deleted", name)
 									}
-								}
-								deleteFileIndex = -1
-								modalActive = true
-								modalTimer = time.Now().Add(modalDuration)
+									return removePath(ctx, fullPath)
+								}, func(err error) {
+									if err != nil {
+										modalText = fmt.Sprintf("Delete error: %v", err)
+									} else {
+										modalText = fmt.Sprintf("Deleted: %s", name)
+									}
+									reloadPanel(p, events)
+									modalActive = true
+									modalTimer = time.Now().Add(modalDuration)
+								})
 							}
 						}
 						if ev.Rune() == 'n' {
 							modalActive = false
 							deleteIndex = -1
-							deleteFileIndex = -1
+							deletePath = ""
 						}
 					}
 					// после обработки подтверждения возвращаемся к верхнему циклу
 					continue
 				}
 
+				if pgr != nil {
+					switch {
+					case ev.Key() == tcell.KeyRune && (ev.Rune() == '/' || ev.Rune() == '?'):
+						label, backward := "Search:", false
+						if ev.Rune() == '?' {
+							label, backward = "Search backward:", true
+						}
+						p := pgr
+						openPrompt(label, p.query, func(q string) {
+							p.search(q, backward)
+						})
+					case !pagerKey(pgr, ev):
+						pgr = nil
+					}
+					continue
+				}
+
+				if dv != nil {
+					if !diffKey(dv, ev) {
+						dv = nil
+						reloadPanel(filelist, events)
+					}
+					continue
+				}
+
+				if sortMenuActive {
+					o, ok := sortMenuKey(filelist.sort, ev.Rune())
+					if ev.Key() == tcell.KeyRune && ok {
+						setSort(filelist.path, o)
+						resortPanel(filelist, o)
+					}
+					if ev.Key() == tcell.KeyRune && ev.Rune() == 'l' {
+						config.Collation = nextCollation(config.Collation)
+						saveConfig(config)
+						setupCollation()
+						resortPanel(filelist, filelist.sort)
+					}
+					// переключатели оставляют меню открытым, выбор поля — закрывает
+					if ev.Key() != tcell.KeyRune || !strings.ContainsRune("rdl", ev.Rune()) {
+						sortMenuActive = false
+					}
+					continue
+				}
+
+				if filterEditing {
+					f := filelist.filter
+					switch ev.Key() {
+					case tcell.KeyEscape:
+						filelist.filter = nil
+						filterEditing = false
+						refilter(filelist)
+						continue
+					case tcell.KeyEnter:
+						filterEditing = false
+						if f.text == "" {
+							filelist.filter = nil
+						}
+						continue
+					case tcell.KeyTAB:
+						f.mode = filterModes[(slices.Index(filterModes, f.mode)+1)%len(filterModes)]
+					case tcell.KeyCtrlP:
+						f.pinned = !f.pinned
+					case tcell.KeyBackspace, tcell.KeyBackspace2:
+						if r := []rune(f.text); len(r) > 0 {
+							f.text = string(r[:len(r)-1])
+						}
+					case tcell.KeyRune:
+						f.text += string(ev.Rune())
+					case tcell.KeyUp, tcell.KeyDown:
+						// стрелки двигают курсор по отфильтрованному списку
+						if ev.Key() == tcell.KeyUp {
+							filelist.cursor--
+						} else {
+							filelist.cursor++
+						}
+						ensureCursorBounds(filelist)
+						continue
+					default:
+						continue
+					}
+					f.compile()
+					refilter(filelist)
+					continue
+				}
+
+				if histPopup != nil {
+					if !listPopupKey(histPopup, ev) {
+						if ev.Key() == tcell.KeyEnter && len(histPopup.items) > 0 {
+							historyGo(filelist, historyIndex(filelist, histPopup.cursor), events)
+						}
+						if ev.Key() == tcell.KeyEnter || ev.Key() == tcell.KeyEscape {
+							histPopup = nil
+						}
+					}
+					continue
+				}
+
+				if finderPopup != nil {
+					switch ev.Key() {
+					case tcell.KeyEscape:
+						finderPopup.stop()
+						finderPopup = nil
+					case tcell.KeyEnter:
+						if target, ok := finderPopup.selected(); ok {
+							// переходим в каталог найденного с курсором на нём
+							openDir(filelist, parentPath(target), baseName(target))
+							if current != 1 {
+								panels[current].active = false
+								current = 1
+								panels[current].active = true
+							}
+						}
+						finderPopup.stop()
+						finderPopup = nil
+					default:
+						finderKey(finderPopup, ev)
+					}
+					continue
+				}
+
+				if grepResults != nil {
+					if grepKey(grepResults, ev) {
+						continue
+					}
+					target, line, ok := grepResults.selected()
+					switch {
+					case ev.Key() == tcell.KeyEscape:
+						grepResults.stop()
+						grepResults = nil
+					case ev.Key() == tcell.KeyEnter && ok:
+						openDir(filelist, parentPath(target), baseName(target))
+						if current != 1 {
+							panels[current].active = false
+							current = 1
+							panels[current].active = true
+						}
+						grepResults.stop()
+						grepResults = nil
+					case ev.Key() == tcell.KeyRune && ev.Rune() == 'e' && ok:
+						// результаты остаются открытыми, чтобы перейти к следующему
+						if err := openEditor(s, target, line); err != nil {
+							modalText = fmt.Sprintf("Editor error: %v", err)
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+						}
+					}
+					continue
+				}
+
 				// обычная обработка клавиш
 				switch ev.Key() {
 				case tcell.KeyEscape:
@@ -578,8 +1373,27 @@ func main() {
 						// закрываем модалку, не выходим
 						modalActive = false
 						deleteIndex = -1
-						deleteFileIndex = -1
+						deletePath = ""
 						modalTimer = time.Time{}
+					} else if op != nil {
+						// прерываем копирование; итог сообщит обработчик fileOpEvent
+						op.cancel()
+					} else if filelist.filter != nil {
+						filelist.filter = nil
+						refilter(filelist)
+					} else if filelist.loading != nil {
+						// прерываем чтение каталога
+						cancelLoad(filelist)
+					} else if filelist.query != nil {
+						// из результатов поиска возвращаемся к самому каталогу
+						openDir(filelist, filelist.path, "")
+					} else if share != nil {
+						// сначала останавливаем раздачу, выход — повторным ESC
+						share.stop()
+						share = nil
+						modalText = "Sharing stopped"
+						modalActive = true
+						modalTimer = time.Now().Add(modalDuration)
 					} else {
 						quit = true
 					}
@@ -588,11 +1402,22 @@ func main() {
 					quit = true
 
 				case tcell.KeyTAB:
+					if dual {
+						focusList(otherPane())
+						break
+					}
+					if !sidebarShown() {
+						break
+					}
 					panels[current].active = false
 					current = (current + 1) % len(panels)
 					panels[current].active = true
 
 				case tcell.KeyUp:
+					if ev.Modifiers()&tcell.ModShift != 0 && previewShown {
+						scrollPreview(-1)
+						break
+					}
 					if panels[current].cursor > 0 {
 						panels[current].cursor--
 					}
@@ -602,6 +1427,10 @@ func main() {
 					ensureCursorBounds(panels[current])
 
 				case tcell.KeyDown:
+					if ev.Modifiers()&tcell.ModShift != 0 && previewShown {
+						scrollPreview(1)
+						break
+					}
 					if panels[current].cursor < len(panels[current].items)-1 {
 						panels[current].cursor++
 					}
@@ -610,59 +1439,88 @@ func main() {
 					}
 					ensureCursorBounds(panels[current])
 
+				case tcell.KeyPgUp, tcell.KeyPgDn:
+					// листают просмотр: список страницами не листается
+					if previewShown {
+						page := tabs[activeTab].h - 3
+						if ev.Key() == tcell.KeyPgUp {
+							page = -page
+						}
+						scrollPreview(page)
+					}
+
 				case tcell.KeyRight, tcell.KeyEnter:
-					if current == 1 && len(filelist.items) > 0 && filelist.cursor >= 0 && filelist.cursor < len(filelist.items) {
-						name := filelist.items[filelist.cursor]
-						fullPath := filepath.Join(filelist.path, name)
-						if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
-							if items, err := loadDir(fullPath); err == nil {
-								filelist.path = fullPath
-								filelist.items = items
-								filelist.cursor = 0
-								filelist.offset = 0
-								ensureCursorBounds(filelist)
+					if ev.Key() == tcell.KeyRight && ev.Modifiers()&tcell.ModAlt != 0 {
+						historyGo(filelist, filelist.histPos+1, events)
+					} else if ev.Key() == tcell.KeyRight && current == 1 && filelist.tree != nil {
+						treeExpand(filelist, events)
+					} else if current == 1 && len(filelist.items) > 0 && filelist.cursor >= 0 && filelist.cursor < len(filelist.items) {
+						e := &filelist.items[filelist.cursor]
+						name := e.name
+						fullPath := entryPath(filelist, e)
+						if e.isDir() {
+							openDir(filelist, fullPath, "")
+						} else if isRemote(fullPath) {
+							// удалённый файл сначала скачиваем во временный каталог
+							tmp, err := downloadPath(name)
+							if err != nil {
+								modalText = fmt.Sprintf("Download error: %v", err)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								break
 							}
+							runOp("Download "+name, func(ctx context.Context) error {
+								err := davDownload(ctx, fullPath, tmp)
+								if err != nil {
+									os.Remove(tmp)
+								}
+								return err
+							}, func(err error) {
+								if err != nil {
+									modalText = fmt.Sprintf("Download error: %v", err)
+									modalActive = true
+									modalTimer = time.Now().Add(modalDuration)
+									return
+								}
+								openFile(tmp)
+							})
 						} else {
-							exec.Command("xdg-open", fullPath).Start()
+							openFile(fullPath)
 						}
 					} else if current == 0 && len(sidebar.items) > 0 {
-						bookmark := sidebar.items[sidebar.cursor]
-						var dir string
-						if bookmark == "Home" {
-							dir = homeDir
-						} else {
-							dir = bookmark
-						}
-						if items, err := loadDir(dir); err == nil {
-							filelist.path = dir
-							filelist.items = items
-							filelist.cursor = 0
-							filelist.offset = 0
-							ensureCursorBounds(filelist)
-						}
+						openDir(filelist, entryPath(sidebar, &sidebar.items[sidebar.cursor]), "")
 					}
 
 				case tcell.KeyLeft:
-					if current == 1 && filelist.path != "/" {
-						parent := filepath.Dir(filelist.path)
-						if items, err := loadDir(parent); err == nil {
-							filelist.path = parent
-							filelist.items = items
-							filelist.cursor = 0
-							filelist.offset = 0
-							ensureCursorBounds(filelist)
+					if ev.Modifiers()&tcell.ModAlt != 0 {
+						historyGo(filelist, filelist.histPos-1, events)
+					} else if current == 1 && filelist.tree != nil && treeCollapse(filelist) {
+						// свернули узел или перешли к родительскому
+					} else if current == 1 && filelist.query != nil {
+						// выход из результатов поиска: курсор на верхнем каталоге найденного
+						name := ""
+						if e := selectedEntry(filelist); e != nil {
+							name = strings.SplitN(filepath.ToSlash(e.name), "/", 2)[0]
 						}
+						openDir(filelist, filelist.path, name)
+					} else if current == 1 && !isRootPath(filelist.path) {
+						// в родителе курсор встаёт на каталог, из которого вышли
+						openDir(filelist, parentPath(filelist.path), baseName(filelist.path))
 					}
 
+				case tcell.KeyF5:
+					transfer(false)
+
+				case tcell.KeyF6:
+					transfer(true)
+
 				case tcell.KeyDelete: // удаление файла/директории (требует подтверждения)
 					if current == 1 && len(filelist.items) > 0 {
-						idx := filelist.cursor
-						if idx >= 0 && idx < len(filelist.items) {
-							name := filelist.items[idx]
-							modalText = fmt.Sprintf("Delete \"%s\"? (y/n)", name)
+						if e := selectedEntry(filelist); e != nil {
+							modalText = fmt.Sprintf("Delete \"%s\"? (y/n)", e.name)
 							modalActive = true
 							modalTimer = time.Time{} // подтверждение — без таймера
-							deleteFileIndex = idx
+							deletePath, deleteName, deletePanel = entryPath(filelist, e), e.name, filelist
 						}
 					}
 
@@ -673,6 +1531,12 @@ func main() {
 						continue
 					}
 
+					// Alt+1…9 — переход на вкладку по номеру
+					if ev.Modifiers()&tcell.ModAlt != 0 && ev.Rune() >= '1' && ev.Rune() <= '9' {
+						switchTab(int(ev.Rune() - '1'))
+						continue
+					}
+
 					switch ev.Rune() {
 					case '?':
 						// Показываем помощь
@@ -682,14 +1546,14 @@ func main() {
 						path := filelist.path
 						exists := false
 						for _, bm := range sidebar.items {
-							if bm == path {
+							if bm.name == path {
 								exists = true
 								break
 							}
 						}
 						if !exists {
-							sidebar.items = append(sidebar.items, path)
-							saveBookmarks(sidebar.items)
+							sidebar.items = append(sidebar.items, bookmarkEntry(path))
+							saveBookmarks(entryNames(sidebar.items))
 							modalText = "Bookmark added"
 							modalActive = true
 							modalTimer = time.Now().Add(modalDuration)
@@ -699,30 +1563,326 @@ func main() {
 					case 'd':
 						if current == 0 && len(sidebar.items) > 0 {
 							idx := sidebar.cursor
-							if idx >= 0 && sidebar.items[idx] != "Home" {
-								modalText = fmt.Sprintf("Delete bookmark \"%s\"? (y/n)", filepath.Base(sidebar.items[idx]))
+							if idx >= 0 && sidebar.items[idx].name != "Home" {
+								modalText = fmt.Sprintf("Delete bookmark \"%s\"? (y/n)", baseName(sidebar.items[idx].name))
 								modalActive = true
 								modalTimer = time.Time{} // подтверждение — без таймера
 								deleteIndex = idx
 							}
 						}
 
+					case '[':
+						historyGo(filelist, filelist.histPos-1, events)
+
+					case ']':
+						historyGo(filelist, filelist.histPos+1, events)
+
+					case 'h':
+						histPopup = historyPopup(filelist)
+
+					case 'f':
+						if isRemote(filelist.path) {
+							modalText = "Find works on local directories only"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						finderPopup = startFinder(filelist.path, events)
+
+					case 'q':
+						if isRemote(filelist.path) {
+							modalText = "Find works on local directories only"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						initial := ""
+						if filelist.query != nil {
+							initial = filelist.query.text
+						}
+						root := filelist.path
+						openPrompt("Find (size>10M mtime<7d ext:log type:f name:~re):", initial, func(input string) {
+							if strings.TrimSpace(input) == "" {
+								return
+							}
+							q, err := parseQuery(input)
+							if err != nil {
+								modalText = fmt.Sprintf("Query error: %v", err)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							startQuery(filelist, root, q, "", events)
+						})
+
+					case 't':
+						// новая вкладка повторяет текущую: те же каталоги, элементы и фокус
+						dup := func(p *Panel) *Panel {
+							t := newTab(p, p.path)
+							t.sort = p.sort
+							name := ""
+							if e := selectedEntry(p); e != nil {
+								name = e.name
+							}
+							openDir(t, t.path, name)
+							return t
+						}
+						right := tabs[activeTab]
+						t := dup(right)
+						if dual {
+							t.left = dup(pane)
+						} else {
+							t.left = newTab(pane, pane.path)
+						}
+						t.leftFocus = filelist == pane
+						tabs = slices.Insert(tabs, activeTab+1, t)
+						switchTab(activeTab + 1)
+
+					case 'x':
+						if len(tabs) == 1 {
+							modalText = "Can't close the last tab"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						closed := activeTab
+						cancelLoad(tabs[closed])
+						cancelLoad(tabs[closed].left)
+						tabs = slices.Delete(tabs, closed, closed+1)
+						// switchTab не сработает на том же индексе и запомнил бы фокус
+						// закрытой вкладки — показываем соседнюю напрямую
+						showTab(min(closed, len(tabs)-1))
+
+					case '{':
+						switchTab((activeTab + len(tabs) - 1) % len(tabs))
+
+					case '}':
+						switchTab((activeTab + 1) % len(tabs))
+
+					case '<', '>':
+						to := activeTab - 1
+						if ev.Rune() == '>' {
+							to = activeTab + 1
+						}
+						if to >= 0 && to < len(tabs) {
+							tabs[activeTab], tabs[to] = tabs[to], tabs[activeTab]
+							activeTab = to
+							saveTabs(tabs, activeTab)
+						}
+
+					case 'C':
+						transfer(false)
+
+					case 'M':
+						transfer(true)
+
+					case 'L':
+						config.Layout = layouts[(slices.Index(layouts, config.Layout)+1)%len(layouts)]
+						saveConfig(config)
+						applyLayout()
+						if dual && pane.loading == nil && pane.all == nil {
+							openDir(pane, pane.path, "")
+						}
+						modalText = fmt.Sprintf("Layout: %s", config.Layout)
+						modalActive = true
+						modalTimer = time.Now().Add(modalDuration)
+
+					case 'B':
+						config.HideSidebar = !config.HideSidebar
+						saveConfig(config)
+						applyLayout()
+
+					case 'P':
+						if config.Layout != "sidebar" {
+							modalText = "Preview pane: sidebar layout only (always on in miller)"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						config.Preview = !config.Preview
+						saveConfig(config)
+						applyLayout()
+
+					case 'W':
+						config.PreviewWrap = !config.PreviewWrap
+						saveConfig(config)
+
+					case 'N':
+						config.LineNumbers = !config.LineNumbers
+						saveConfig(config)
+
+					case 'z', 'Z':
+						d := preview
+						if !previewShown || d == nil || d.json == nil {
+							modalText = "Folding works in the JSON preview"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						if ev.Rune() == 'z' {
+							d.toggleFold()
+						} else {
+							d.foldAll()
+						}
+						scrollPreview(0)
+
+					case 'O':
+						d := preview
+						if !previewShown || d == nil || !d.hex {
+							modalText = "Offset jump works in the hex preview"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						openPrompt("Jump to offset (0x1f00, 4096, 16k, 50%):", "", func(input string) {
+							off, err := parseOffset(input, d.size)
+							if err != nil || off < 0 {
+								modalText = fmt.Sprintf("Bad offset %q", input)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							// строка с нужным байтом встаёт наверх
+							bpr := int64(hexRowBytes(previewW - 2))
+							previewTop = min(off/bpr*bpr, hexMaxTop(d.size, tabs[activeTab].h-2, int(bpr)))
+						})
+
+					case 'j':
+						openPrompt("Jump to (Tab - list):", "", func(input string) {
+							if strings.TrimSpace(input) == "" {
+								return
+							}
+							// выбранный из списка вариант — готовый путь
+							if info, err := os.Stat(input); err == nil && info.IsDir() && filepath.IsAbs(input) {
+								openDir(filelist, input, "")
+								return
+							}
+							dirs := frecencyRank(input, filelist.path)
+							if len(dirs) == 0 {
+								modalText = fmt.Sprintf("No match for \"%s\"", input)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							openDir(filelist, dirs[0], "")
+						})
+						cur := filelist.path
+						promptComplete = func(input string) []string {
+							return frecencyRank(input, cur)
+						}
+						promptNoPrefix = true
+
+					case 'F':
+						if isRemote(filelist.path) {
+							modalText = "Search works on local directories only"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						root := filelist.path
+						openPrompt("Search text (/regex/):", "", func(query string) {
+							if query == "" {
+								return
+							}
+							g, err := startGrep(root, query, events)
+							if err != nil {
+								modalText = fmt.Sprintf("Bad pattern: %v", err)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							grepResults = g
+						})
+
+					case '/':
+						if filelist.filter == nil {
+							filelist.filter = newFilter()
+						}
+						filterEditing = true
+						if current != 1 {
+							panels[current].active = false
+							current = 1
+							panels[current].active = true
+						}
+
+					case 'g':
+						openPrompt("Go to (Tab - complete):", "", func(input string) {
+							if strings.TrimSpace(input) == "" {
+								return
+							}
+							target := resolvePath(input, filelist.path)
+							info, err := statPath(davCtx, target)
+							switch {
+							case err != nil:
+								modalText = fmt.Sprintf("No such directory: %s", input)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+							case info.IsDir():
+								openDir(filelist, target, "")
+							default:
+								// файл — открываем его каталог с курсором на нём
+								openDir(filelist, parentPath(target), baseName(target))
+							}
+						})
+						var known []string
+						for _, b := range sidebar.items {
+							if b.name != "Home" {
+								known = append(known, b.name)
+							}
+						}
+						for i := len(filelist.history) - 1; i >= 0; i-- {
+							known = append(known, filelist.history[i].path)
+						}
+						dir := filelist.path
+						promptComplete = func(input string) []string {
+							return completePath(input, dir, known)
+						}
+
+					case 's':
+						if current == 1 {
+							sortMenuActive = true
+						}
+
+					case 'v':
+						if current == 1 {
+							filelist.detailed = !filelist.detailed
+						}
+
+					case 'T':
+						if filelist.query != nil || filelist.path == "" {
+							modalText = "Tree view is not available here"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						toggleTree(filelist)
+
+					case 'E':
+						if filelist.tree == nil {
+							break
+						}
+						openPrompt("Expand to depth:", "2", func(input string) {
+							depth, err := strconv.Atoi(strings.TrimSpace(input))
+							if err != nil || depth < 1 {
+								modalText = fmt.Sprintf("Bad depth %q", input)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							treeExpandAll(filelist, depth, events)
+						})
+
 					case '.':
 						showHidden = !showHidden
-						if items, err := loadDir(filelist.path); err == nil {
-							filelist.items = items
-							filelist.cursor = 0
-							filelist.offset = 0
-							ensureCursorBounds(filelist)
-						}
+						reloadPanel(filelist, events)
 						modalText = "Toggled hidden files"
 						modalActive = true
 						modalTimer = time.Now().Add(modalDuration)
 
 					case 'm':
 						if current == 1 && len(filelist.items) > 0 {
-							name := filelist.items[filelist.cursor]
-							moveSrc = filepath.Join(filelist.path, name)
+							name := filelist.items[filelist.cursor].name
+							moveSrc = entryPath(filelist, &filelist.items[filelist.cursor])
 							moveReady = true
 							copyReady = false
 							modalText = fmt.Sprintf("Marked for move: %s", name)
@@ -732,8 +1892,8 @@ func main() {
 
 					case 'c':
 						if current == 1 && len(filelist.items) > 0 {
-							name := filelist.items[filelist.cursor]
-							copySrc = filepath.Join(filelist.path, name)
+							name := filelist.items[filelist.cursor].name
+							copySrc = entryPath(filelist, &filelist.items[filelist.cursor])
 							copyReady = true
 							moveReady = false
 							modalText = fmt.Sprintf("Marked for copy: %s", name)
@@ -742,74 +1902,308 @@ func main() {
 						}
 
 					case 'p':
+						p := filelist
 						if moveReady {
-							dst := filepath.Join(filelist.path, filepath.Base(moveSrc))
-							err := os.Rename(moveSrc, dst)
-							if err != nil {
-								modalText = fmt.Sprintf("Move error: %v", err)
-							} else {
-								modalText = fmt.Sprintf("Moved to: %s", filelist.path)
-								moveReady = false
-								moveSrc = ""
-								if items, err := loadDir(filelist.path); err == nil {
-									filelist.items = items
-									filelist.cursor = 0
-									filelist.offset = 0
-									ensureCursorBounds(filelist)
+							src := moveSrc
+							dst := joinPath(p.path, baseName(src))
+							runOp("Move "+baseName(src), func(ctx context.Context) error {
+								return renamePath(ctx, src, dst)
+							}, func(err error) {
+								if err != nil {
+									modalText = fmt.Sprintf("Move error: %v", err)
+								} else {
+									modalText = fmt.Sprintf("Moved to: %s", displayPath(p.path))
+									if moveSrc == src {
+										moveReady = false
+										moveSrc = ""
+									}
+									reloadPanel(p, events)
 								}
-							}
-							modalActive = true
-							modalTimer = time.Now().Add(modalDuration)
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+							})
 
 						} else if copyReady {
-							dst := filepath.Join(filelist.path, filepath.Base(copySrc))
-							err := copyRecursive(copySrc, dst)
-							if err != nil {
-								modalText = fmt.Sprintf("Copy error: %v", err)
-							} else {
-								modalText = fmt.Sprintf("Copied to: %s", filelist.path)
-								copyReady = false
-								copySrc = ""
-								if items, err := loadDir(filelist.path); err == nil {
-									filelist.items = items
-									filelist.cursor = 0
-									filelist.offset = 0
-									ensureCursorBounds(filelist)
+							src := copySrc
+							dst := joinPath(p.path, baseName(src))
+							runOp("Copy "+baseName(src), func(ctx context.Context) error {
+								return copyPath(ctx, src, dst)
+							}, func(err error) {
+								if err != nil {
+									modalText = fmt.Sprintf("Copy error: %v", err)
+								} else {
+									modalText = fmt.Sprintf("Copied to: %s", displayPath(p.path))
+									if copySrc == src {
+										copyReady = false
+										copySrc = ""
+									}
+									reloadPanel(p, events)
 								}
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+							})
+						}
+
+					case 'D':
+						// первый D отмечает файл, второй сравнивает с ним; в раскладке
+						// dual без отметки сравниваются файлы под курсорами обеих панелей
+						e := selectedEntry(filelist)
+						if current != 1 || e == nil {
+							break
+						}
+						target := entryPath(filelist, e)
+						left := diffSrc
+						if o := selectedEntry(otherPane()); dual && left == "" && o != nil && !o.isDir() {
+							left = entryPath(otherPane(), o)
+						}
+						if e.isDir() || left == "" || left == target {
+							if e.isDir() {
+								modalText = "Diff compares files, not directories"
+							} else {
+								diffSrc = target
+								modalText = fmt.Sprintf("Marked for diff: %s (D on another file to compare)", e.name)
 							}
 							modalActive = true
 							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						d, err := openDiff(left, target, config.TabWidth)
+						if err != nil {
+							modalText = fmt.Sprintf("Diff error: %v", err)
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
 						}
+						diffSrc = ""
+						dv = d
 
 					case 'r':
-						// refresh текущей директории
-						if items, err := loadDir(filelist.path); err == nil {
-							filelist.items = items
-							filelist.cursor = 0
-							filelist.offset = 0
-							ensureCursorBounds(filelist)
-							modalText = "Refreshed"
-						} else {
-							modalText = fmt.Sprintf("Refresh error: %v", err)
+						// refresh текущей директории; об итоге сообщит обработчик загрузки
+						reloadPanel(filelist, events)
+						refreshPanel, refreshGen = filelist, filelist.loadGen
+
+					case 'R':
+						if current == 1 && len(filelist.items) > 0 {
+							oldName := filelist.items[filelist.cursor].name
+							// в дереве и результатах поиска имя — путь от каталога панели;
+							// переименовываем только последний элемент
+							openPrompt("Rename to:", filepath.Base(oldName), func(newName string) {
+								if newName == "" || newName == filepath.Base(oldName) {
+									return
+								}
+								if err := checkName(newName); err != nil {
+									modalText = fmt.Sprintf("Rename error: %v", err)
+									modalActive = true
+									modalTimer = time.Now().Add(modalDuration)
+									return
+								}
+								p, dir := filelist, filelist.path
+								src := joinPath(dir, oldName)
+								dst := joinPath(parentPath(src), newName)
+								rel := filepath.Join(filepath.Dir(oldName), newName)
+								runOp("Rename "+oldName, func(ctx context.Context) error {
+									return renamePath(ctx, src, dst)
+								}, func(err error) {
+									if err != nil {
+										modalText = fmt.Sprintf("Rename error: %v", err)
+									} else {
+										modalText = fmt.Sprintf("Renamed to: %s", newName)
+										if p.path == dir {
+											reloadPanel(p, events)
+											p.loading.selectName = rel
+											if p.tree != nil {
+												p.tree.want = rel
+											}
+										}
+									}
+									modalActive = true
+									modalTimer = time.Now().Add(modalDuration)
+								})
+							})
 						}
-						modalActive = true
-						modalTimer = time.Now().Add(modalDuration)
+
+					case 'u':
+						openPrompt("WebDAV URL (http[s]://[user:pass@]host/path):", "https://", func(input string) {
+							dir := normalizePath(input)
+							if !isRemote(dir) {
+								modalText = "Not a WebDAV URL"
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							openDir(filelist, dir, "")
+						})
+
+					case 'w':
+						if share != nil {
+							share.stop()
+							share = nil
+							modalText = "Sharing stopped"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						if isRemote(filelist.path) {
+							modalText = "Only local directories can be shared"
+							modalActive = true
+							modalTimer = time.Now().Add(modalDuration)
+							break
+						}
+						dir := filelist.path
+						openPrompt("Share port:", "8080", func(portText string) {
+							port, err := strconv.Atoi(portText)
+							if err != nil || port < 0 || port > 65535 {
+								modalText = "Invalid port"
+								modalActive = true
+								modalTimer = time.Now().Add(modalDuration)
+								return
+							}
+							openPrompt("Access mode (ro/rw):", "ro", func(mode string) {
+								// во всю сеть — только если попросили явно
+								openPrompt("Listen on (local/all):", "local", func(listen string) {
+									sh, err := startShare(dir, port, mode == "rw", listen == "all")
+									if err != nil {
+										modalText = fmt.Sprintf("Share error: %v", err)
+										modalActive = true
+										modalTimer = time.Now().Add(modalDuration)
+										return
+									}
+									share = sh
+								})
+							})
+						})
 					}
 				}
+
+			case *fsChangeEvent:
+				// заменённый файл (сохранение через rename) ядро перестаёт
+				// отслеживать — на следующем кадре наблюдение ставится заново
+				watchKey = ""
+				if previewFor != "" && ev.has(previewFor) {
+					// просмотр перечитается на следующем кадре
+					previewStale = true
+				}
+				if o := otherPane(); dual && o.loading == nil && ev.has(o.path) {
+					reloadPanel(o, events)
+				}
+				// удалённый каталог обработает applyDirLoad, поднявшись к родителю
+				if filelist.loading != nil {
+					continue
+				}
+				e := selectedEntry(filelist)
+				if ev.has(filelist.path) {
+					reloadPanel(filelist, events)
+				} else if e != nil && ev.has(entryPath(filelist, e)) {
+					// изменилась только цель под курсором — обновляем её метаданные
+					refreshEntry(filelist, e)
+				}
+
+			case *dirCountEvent:
+				applyDirCount(ev)
+
+			case *fileOpEvent:
+				if op == nil || ev.gen != op.gen {
+					break
+				}
+				finished := op
+				op = nil
+				if errors.Is(ev.err, context.Canceled) {
+					// прерванная операция могла успеть часть работы
+					modalText = fmt.Sprintf("Cancelled: %s", finished.text)
+					modalActive = true
+					modalTimer = time.Now().Add(modalDuration)
+					reloadPanel(filelist, events)
+					if dual {
+						reloadPanel(otherPane(), events)
+					}
+					break
+				}
+				finished.done(ev.err)
+
+			case *pagerEvent:
+				if pgr == nil || ev.p != pgr || ev.gen != pgr.gen {
+					break
+				}
+				if err := pgr.apply(ev); err == errNotText {
+					pgr = nil
+					exec.Command("xdg-open", ev.p.path).Start()
+				} else if err != nil {
+					pgr = nil
+					modalText = fmt.Sprintf("Open error: %v", err)
+					modalActive = true
+					modalTimer = time.Now().Add(modalDuration)
+				}
+
+			case *treeLoadEvent:
+				applyTreeLoad(ev, events)
+
+			case *previewEvent:
+				if ev.gen == previewWant {
+					preview = ev.data
+					preview.arrange()
+				}
+
+			case *grepEvent:
+				if grepResults != nil && grepResults.gen == ev.gen {
+					grepResults.apply(ev)
+				}
+
+			case *finderBatchEvent:
+				if finderPopup != nil && finderPopup.gen == ev.gen {
+					finderPopup.apply(ev)
+				}
+
+			case *dirLoadEvent:
+				finished := ev.panel == refreshPanel && ev.gen == refreshGen && (ev.done || ev.err != nil)
+				if err := applyDirLoad(ev.panel, ev, events); err != nil {
+					modalText = fmt.Sprintf("Open error: %v", err)
+					modalActive = true
+					modalTimer = time.Now().Add(modalDuration)
+				} else if finished && ev.done {
+					modalText = "Refreshed"
+					modalActive = true
+					modalTimer = time.Now().Add(modalDuration)
+				}
+				if finished {
+					refreshPanel = nil
+				}
 			}
 		case <-timerChan:
 			// таймер сработал — закрываем модалку
 			modalActive = false
 			modalTimer = time.Time{}
+		case <-followChan:
+			if pgr != nil && pgr.follow {
+				pgr.poll()
+			}
+		case <-loadTimerChan:
+			for _, p := range loadPanels {
+				ld := p.loading
+				if ld == nil || time.Since(ld.progress) < loadTimeout {
+					continue
+				}
+				cancelLoad(p)
+				if p == parentCol && !ld.switched {
+					// иначе колонка родителя на следующем кадре начнёт чтение заново
+					p.path, p.items, p.all = ld.path, nil, nil
+				}
+				modalText = fmt.Sprintf("Timed out reading %s", displayPath(ld.path))
+				modalActive = true
+				modalTimer = time.Now().Add(modalDuration)
+			}
 		}
 	}
+	flushFrecency()
 
 	// (опционально) закрываем канал, чтобы горутина завершилась корректно
 
 }
 
 // ---------------- copy util ----------------
-func copyRecursive(src, dst string) error {
+func copyRecursive(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	info, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -824,7 +2218,7 @@ func copyRecursive(src, dst string) error {
 			return err
 		}
 		for _, e := range entries {
-			err = copyRecursive(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()))
+			err = copyRecursive(ctx, filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()))
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,717 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	textunicode "golang.org/x/text/encoding/unicode"
+)
+
+// ---------------- pager ----------------
+// Встроенный просмотрщик текстовых файлов на весь экран: прокрутка, поиск
+// вперёд и назад с подсветкой, перенос строк и слежение за растущим файлом,
+// как tail -f. Кодировка определяется по началу файла и переключается
+// вручную, если угадана неверно. Файл читается и декодируется в фоне, готовый
+// текст приходит в канал событий как *pagerEvent.
+const (
+	pagerMaxBytes  = 32 << 20               // больше в памяти не держим: при слежении старые строки уходят
+	followInterval = 500 * time.Millisecond // как часто проверяем файл в режиме слежения
+)
+
+// errNotText — файл двоичный, его открываем внешней программой
+var errNotText = errors.New("not a text file")
+
+var pagerEncodings = []string{"utf-8", "utf-16le", "utf-16be", "windows-1251", "koi8-r"}
+
+type pager struct {
+	path     string
+	enc      string
+	bom      int // длина BOM в начале файла
+	tabWidth int
+	info     os.FileInfo // прочитанный файл, nil — ещё не прочитан; по нему poll замечает ротацию
+
+	gen     int  // поколение чтения: ответы на отменённые запросы отбрасываются
+	loading bool // идёт чтение в фоне
+	out     chan<- tcell.Event
+
+	lines   []string // без перевода строки; табуляции раскрываются при выводе
+	open    bool     // последняя строка ещё не закончена переводом строки
+	bytes   int      // сколько байт текста в lines
+	size    int64    // до какого места файл прочитан
+	pending []byte   // недекодированный хвост: половина символа UTF-8 или UTF-16
+	dropped bool     // начало файла не поместилось в память
+	cut     bool     // конец файла не прочитан: показаны первые pagerMaxBytes
+
+	top, left    int
+	wrap, follow bool
+
+	query     string
+	backward  bool
+	matchLine int // найденное вхождение: строка и позиция в рунах, -1 — нет
+	matchCol  int
+
+	msg  string // сообщение в нижней строке до следующей клавиши
+	w, h int    // размер области текста при последней отрисовке
+}
+
+// pagerEvent несёт прочитанный в фоне текст: весь файл заново (reload) или
+// то, что дописано с прошлого чтения
+type pagerEvent struct {
+	when    time.Time
+	p       *pager
+	gen     int
+	reload  bool
+	enc     string
+	bom     int
+	info    os.FileInfo
+	dropped bool     // начало файла пропущено
+	cut     bool     // конец файла не прочитан
+	size    int64    // до какого места файл прочитан
+	pending []byte   // недекодированный хвост
+	parts   []string // текст, разбитый по переводам строки
+	n       int      // его длина в байтах
+	msg     string
+	err     error
+}
+
+func (e *pagerEvent) When() time.Time { return e.when }
+
+// openPager открывает просмотр и запускает чтение файла; если файл окажется
+// не текстовым, придёт событие с errNotText
+func openPager(path string, tabWidth int, out chan<- tcell.Event) *pager {
+	p := &pager{path: path, tabWidth: max(tabWidth, 1), matchLine: -1, wrap: config.PreviewWrap, out: out}
+	p.load("")
+	return p
+}
+
+// request запускает read в фоне новым поколением; прежние запросы устаревают
+func (p *pager) request(read func() *pagerEvent) {
+	p.gen++
+	p.loading = true
+	gen, out := p.gen, p.out
+	go func() {
+		ev := read()
+		ev.when, ev.p, ev.gen = time.Now(), p, gen
+		out <- ev
+	}()
+}
+
+// load перечитывает файл целиком; enc "" — определить кодировку заново
+func (p *pager) load(enc string) {
+	path, follow := p.path, p.follow
+	p.request(func() *pagerEvent { return readPager(path, enc, follow) })
+}
+
+// poll проверяет в фоне, не вырос ли файл; усечённый или подменённый файл
+// (ротация логов) читается заново
+func (p *pager) poll() {
+	if p.loading || p.info == nil {
+		return
+	}
+	path, info, enc, size, pending := p.path, p.info, p.enc, p.size, bytes.Clone(p.pending)
+	p.request(func() *pagerEvent { return pollPager(path, info, enc, size, pending) })
+}
+
+// readPager читает первые pagerMaxBytes файла, а при слежении — последние
+func readPager(path, enc string, follow bool) *pagerEvent {
+	ev := &pagerEvent{reload: true}
+	f, err := os.Open(path)
+	if err != nil {
+		ev.err = err
+		return ev
+	}
+	defer f.Close()
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		ev.err = err
+		return ev
+	}
+	head = head[:n]
+	detected, bom, ok := detectEncoding(head)
+	if enc == "" {
+		if !ok {
+			ev.err = errNotText
+			return ev
+		}
+		enc = detected
+	}
+	if enc != detected {
+		bom = 0
+	}
+	ev.enc, ev.bom = enc, bom
+	if ev.info, err = f.Stat(); err != nil {
+		ev.err = err
+		return ev
+	}
+
+	// большой файл показываем с начала, а при слежении — его конец
+	from := int64(bom)
+	if size := ev.info.Size(); size-from > pagerMaxBytes {
+		if follow {
+			from = size - pagerMaxBytes
+			ev.dropped = true
+		} else {
+			ev.cut = true
+		}
+	}
+	readPagerText(f, ev, from, nil)
+	return ev
+}
+
+func pollPager(path string, info os.FileInfo, enc string, size int64, pending []byte) *pagerEvent {
+	reload := func(msg string) *pagerEvent {
+		ev := readPager(path, enc, true)
+		ev.msg = msg
+		return ev
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return &pagerEvent{err: err}
+	}
+	defer f.Close()
+	cur, err := f.Stat()
+	switch {
+	case err != nil:
+		return &pagerEvent{err: err}
+	case !os.SameFile(cur, info):
+		return reload("File replaced, reloaded")
+	case cur.Size() < size:
+		return reload("File truncated, reloaded")
+	case cur.Size() == size:
+		return &pagerEvent{enc: enc, size: size, pending: pending}
+	}
+	ev := &pagerEvent{enc: enc}
+	readPagerText(f, ev, size, pending)
+	return ev
+}
+
+// readPagerText дочитывает файл от from, не больше pagerMaxBytes за раз;
+// pending — недекодированный хвост прошлого чтения
+func readPagerText(f *os.File, ev *pagerEvent, from int64, pending []byte) {
+	buf, err := io.ReadAll(io.NewSectionReader(f, from, pagerMaxBytes))
+	if err != nil {
+		ev.err = err
+		return
+	}
+	d := &pager{enc: ev.enc, pending: pending}
+	text := d.decode(buf)
+	ev.size, ev.pending = from+int64(len(buf)), d.pending
+	if text != "" {
+		ev.parts, ev.n = strings.Split(text, "\n"), len(text)
+	}
+}
+
+// apply принимает прочитанное; ошибка возвращается, только если показать
+// ещё нечего — тогда просмотр закрывается
+func (p *pager) apply(ev *pagerEvent) error {
+	p.loading = false
+	if ev.err != nil {
+		if p.info == nil {
+			return ev.err
+		}
+		p.msg = ev.err.Error()
+		return nil
+	}
+	if ev.reload {
+		p.enc, p.bom, p.info = ev.enc, ev.bom, ev.info
+		p.lines, p.open, p.bytes, p.dropped, p.cut = nil, false, 0, ev.dropped, ev.cut
+		p.matchLine = -1
+	}
+	p.size, p.pending = ev.size, ev.pending
+	if !ev.reload && ev.parts == nil {
+		return nil
+	}
+	p.appendLines(ev.parts, ev.n)
+	if p.follow {
+		p.scrollTo(len(p.lines))
+	} else {
+		p.scrollTo(p.top)
+	}
+	if ev.msg != "" {
+		p.msg = ev.msg
+	}
+	return nil
+}
+
+// decode переводит байты в текст; неполный символ в конце остаётся в pending
+func (p *pager) decode(b []byte) string {
+	b = append(p.pending, b...)
+	p.pending = nil
+	var dec *encoding.Decoder
+	switch p.enc {
+	case "utf-16le", "utf-16be":
+		order := textunicode.LittleEndian
+		hi := 1
+		if p.enc == "utf-16be" {
+			order, hi = textunicode.BigEndian, 0
+		}
+		cut := len(b) &^ 1
+		// старшая половина суррогатной пары ждёт младшую
+		if cut >= 2 && b[cut-2+hi]&0xfc == 0xd8 {
+			cut -= 2
+		}
+		b, p.pending = b[:cut], bytes.Clone(b[cut:])
+		dec = textunicode.UTF16(order, textunicode.IgnoreBOM).NewDecoder()
+	case "windows-1251":
+		dec = charmap.Windows1251.NewDecoder()
+	case "koi8-r":
+		dec = charmap.KOI8R.NewDecoder()
+	default:
+		if i := lastRuneStart(b); i >= 0 && !utf8.FullRune(b[i:]) {
+			b, p.pending = b[:i], bytes.Clone(b[i:])
+		}
+		return strings.ToValidUTF8(string(b), "�")
+	}
+	out, err := dec.Bytes(b)
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+// lastRuneStart — начало последнего символа UTF-8 в b, -1 если не найдено
+func lastRuneStart(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendLines добавляет текст длиной n, разбитый по переводам строки,
+// продолжая незаконченную последнюю строку
+func (p *pager) appendLines(parts []string, n int) {
+	if len(parts) == 0 {
+		return
+	}
+	p.bytes += n
+	if p.open && len(p.lines) > 0 {
+		p.lines[len(p.lines)-1] += parts[0]
+		parts = parts[1:]
+	}
+	p.open = parts[len(parts)-1] != ""
+	if !p.open {
+		parts = parts[:len(parts)-1]
+	}
+	p.lines = append(p.lines, parts...)
+
+	// память ограничена: отбрасываем самые старые строки
+	drop := 0
+	for n := p.bytes; n > pagerMaxBytes && drop < len(p.lines)-1; drop++ {
+		n -= len(p.lines[drop]) + 1
+		p.bytes = n
+	}
+	if drop > 0 {
+		p.lines = p.lines[drop:]
+		p.top = max(0, p.top-drop)
+		p.matchLine = -1
+		p.dropped = true
+	}
+}
+
+// detectEncoding определяет кодировку по началу файла: BOM, корректный
+// UTF-8, UTF-16 без BOM, иначе однобайтовая кириллица.
+// ok false — файл двоичный
+func detectEncoding(head []byte) (enc string, bom int, ok bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8", 3, true
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "utf-16le", 2, true
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "utf-16be", 2, true
+	}
+	if !isBinary(head) {
+		return "utf-8", 0, true
+	}
+	// UTF-16 без BOM: у текста на одном языке старшие байты символов почти
+	// одинаковые (0x00 у латиницы, 0x04 у кириллицы), а младшие — любые
+	var seen [2]map[byte]bool
+	seen[0], seen[1] = map[byte]bool{}, map[byte]bool{}
+	for i, b := range head {
+		seen[i%2][b] = true
+	}
+	if len(head) >= 8 {
+		switch {
+		case len(seen[1]) <= 4 && len(seen[0]) > 2*len(seen[1]) && utf16Text(head, "utf-16le"):
+			return "utf-16le", 0, true
+		case len(seen[0]) <= 4 && len(seen[1]) > 2*len(seen[0]) && utf16Text(head, "utf-16be"):
+			return "utf-16be", 0, true
+		}
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return "", 0, false
+	}
+	// управляющие символы, кроме обычных для текста, — признак двоичного файла
+	var upper, lower int
+	for _, b := range head {
+		switch {
+		case b < 0x20 && !strings.ContainsRune("\t\n\r\f\v\b\x1b", rune(b)):
+			return "", 0, false
+		case b >= 0xc0 && b <= 0xdf:
+			upper++
+		case b >= 0xe0:
+			lower++
+		}
+	}
+	// строчных букв в тексте больше, а в KOI8-R они в 0xc0–0xdf, в
+	// Windows-1251 — в 0xe0–0xff
+	if upper > lower {
+		return "koi8-r", 0, true
+	}
+	return "windows-1251", 0, true
+}
+
+// utf16Text проверяет, что head в кодировке enc — текст без управляющих
+// символов
+func utf16Text(head []byte, enc string) bool {
+	p := &pager{enc: enc}
+	for _, r := range p.decode(head) {
+		if (r < 0x20 && !strings.ContainsRune("\t\n\r\f", r)) || r == utf8.RuneError {
+			return false
+		}
+	}
+	return true
+}
+
+// line — строка i для вывода
+func (p *pager) line(i int) string {
+	return displayLine(p.lines[i], p.tabWidth)
+}
+
+// displayLine готовит строку файла к выводу: табуляции раскрыты,
+// управляющие символы заменены точками
+func displayLine(s string, tabWidth int) string {
+	s = expandTabs(strings.ToValidUTF8(strings.TrimSuffix(s, "\r"), "�"), tabWidth)
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '·'
+		}
+		return r
+	}, s)
+}
+
+// rowsOf — сколько строк экрана занимает строка i
+func (p *pager) rowsOf(i int) int {
+	if !p.wrap || p.w <= 0 {
+		return 1
+	}
+	return max(1, (utf8.RuneCountInString(p.line(i))+p.w-1)/p.w)
+}
+
+// maxTop — первая строка, при которой конец файла внизу экрана; под
+// обрезанным файлом остаётся строка для пометки об этом
+func (p *pager) maxTop() int {
+	top, rows := len(p.lines), 0
+	if p.cut {
+		rows = 1
+	}
+	for top > 0 && rows+p.rowsOf(top-1) <= p.h {
+		top--
+		rows += p.rowsOf(top)
+	}
+	return top
+}
+
+func (p *pager) scrollTo(top int) {
+	p.top = max(0, min(top, p.maxTop()))
+}
+
+// search ищет query от текущего вхождения (или верха экрана) в направлении
+// backward, с переходом через конец файла. Регистр учитывается, только если
+// в запросе есть заглавные буквы.
+func (p *pager) search(query string, backward bool) {
+	p.query, p.backward = query, backward
+	p.matchLine = -1
+	p.next(false)
+}
+
+// next переходит к следующему вхождению; reverse — в обратную сторону
+func (p *pager) next(reverse bool) {
+	if p.query == "" || len(p.lines) == 0 {
+		return
+	}
+	back := p.backward != reverse
+	line, col := p.matchLine, p.matchCol
+	if line < 0 || line >= len(p.lines) || line < p.top || line >= p.top+p.h {
+		// вхождение ушло с экрана — ищем от экрана
+		line, col = p.top, -1
+		if back {
+			line, col = min(p.top+p.h, len(p.lines)-1), 1<<30
+		}
+	}
+	n := len(p.lines)
+	for k := 0; k <= n; k++ {
+		i := line
+		if back {
+			i = ((line-k)%n + n) % n
+		} else {
+			i = (line + k) % n
+		}
+		from, to := 0, 1<<30
+		if k == 0 && !back {
+			from = col + 1
+		}
+		if k == 0 && back {
+			to = col - 1
+		}
+		if k == n && !back {
+			to = col
+		}
+		if k == n && back {
+			from = col
+		}
+		if c := findIn(p.line(i), p.query, from, to, back); c >= 0 {
+			if (back && i > line) || (!back && i < line) || (k == n) {
+				p.msg = "Search wrapped"
+			}
+			p.matchLine, p.matchCol = i, c
+			p.show(i, c)
+			return
+		}
+	}
+	p.msg = fmt.Sprintf("Pattern not found: %s", p.query)
+	if p.cut {
+		p.msg = fmt.Sprintf("Pattern not found in the first %s: %s", humanSize(pagerMaxBytes), p.query)
+	}
+}
+
+// findIn ищет query в line с позиций [from, to] в рунах: первое вхождение
+// или, при last, последнее; -1 — не найдено
+func findIn(line, query string, from, to int, last bool) int {
+	found := -1
+	for _, c := range matchesIn(line, query) {
+		if c < from || c > to {
+			continue
+		}
+		if !last {
+			return c
+		}
+		found = c
+	}
+	return found
+}
+
+// matchesIn — позиции вхождений query в line, в рунах. Регистр сводится по
+// одной руне: строчная форма некоторых букв (İ → i̇) длиннее заглавной, и
+// позиции в приведённой целиком строке разошлись бы с исходной.
+func matchesIn(line, query string) []int {
+	if query == "" {
+		return nil
+	}
+	l, q := []rune(line), []rune(query)
+	if strings.ToLower(query) == query {
+		for i, r := range l {
+			l[i] = unicode.ToLower(r)
+		}
+	}
+	var out []int
+	for i := 0; i+len(q) <= len(l); {
+		if slices.Equal(l[i:i+len(q)], q) {
+			out = append(out, i)
+			i += len(q)
+		} else {
+			i++
+		}
+	}
+	return out
+}
+
+// show прокручивает так, чтобы позиция col строки i была на экране
+func (p *pager) show(i, col int) {
+	if i < p.top || i >= p.top+p.h {
+		p.scrollTo(i - p.h/3)
+	}
+	for !p.wrap && p.w > 0 && (col < p.left || col >= p.left+p.w) {
+		p.left = max(0, col-p.w/3)
+	}
+}
+
+// pagerKey обрабатывает клавишу; false — просмотр закрыт
+func pagerKey(p *pager, ev *tcell.EventKey) bool {
+	p.msg = ""
+	page := max(1, p.h-1)
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		return false
+	case tcell.KeyUp:
+		p.follow = false
+		p.scrollTo(p.top - 1)
+	case tcell.KeyDown, tcell.KeyEnter:
+		p.scrollTo(p.top + 1)
+	case tcell.KeyPgUp:
+		p.follow = false
+		p.scrollTo(p.top - page)
+	case tcell.KeyPgDn:
+		p.scrollTo(p.top + page)
+	case tcell.KeyHome:
+		p.follow = false
+		p.top, p.left = 0, 0
+	case tcell.KeyEnd:
+		p.scrollTo(len(p.lines))
+	case tcell.KeyLeft:
+		p.left = max(0, p.left-8)
+	case tcell.KeyRight:
+		// дальше самой длинной строки на экране не уходим
+		longest := 0
+		for i := p.top; i < len(p.lines) && i < p.top+p.h; i++ {
+			longest = max(longest, utf8.RuneCountInString(p.line(i)))
+		}
+		if !p.wrap && p.left+p.w < longest {
+			p.left += 8
+		}
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false
+		case 'k':
+			p.follow = false
+			p.scrollTo(p.top - 1)
+		case 'j':
+			p.scrollTo(p.top + 1)
+		case 'b':
+			p.follow = false
+			p.scrollTo(p.top - page)
+		case ' ':
+			p.scrollTo(p.top + page)
+		case 'g':
+			p.follow = false
+			p.top, p.left = 0, 0
+		case 'G':
+			p.scrollTo(len(p.lines))
+		case 'n':
+			p.next(false)
+		case 'N':
+			p.next(true)
+		case 'w':
+			p.wrap = !p.wrap
+			p.left = 0
+			p.scrollTo(p.top)
+		case 'F':
+			p.follow = !p.follow
+			if p.follow && p.cut {
+				// показано начало файла — перечитываем его конец
+				p.load(p.enc)
+				p.msg = "Following — reading the end of the file (F to stop)"
+			} else if p.follow {
+				p.poll()
+				p.scrollTo(len(p.lines))
+				p.msg = "Following — waiting for data (F to stop)"
+			}
+		case 'e':
+			if p.info == nil {
+				break
+			}
+			// следующая кодировка по кругу; позиция сохраняется до прихода текста
+			i := 0
+			for k, e := range pagerEncodings {
+				if e == p.enc {
+					i = k
+				}
+			}
+			p.load(pagerEncodings[(i+1)%len(pagerEncodings)])
+		}
+	}
+	return true
+}
+
+// drawPager рисует просмотр на весь экран: заголовок, текст и строку
+// подсказок или сообщения
+func drawPager(s tcell.Screen, p *pager) {
+	sw, sh := s.Size()
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	grayStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	barStyle := tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGray)
+	matchStyle := tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorYellow)
+	currentStyle := tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorOrange)
+	p.w, p.h = sw, max(1, sh-2)
+	s.Fill(' ', tcell.StyleDefault)
+
+	// заголовок: путь, кодировка, позиция и режимы
+	pos := "empty"
+	if n := len(p.lines); n > 0 {
+		pct := 100
+		if n > p.h {
+			pct = min(100, (p.top+p.h)*100/n)
+		}
+		pos = fmt.Sprintf("%d/%d %d%%", p.top+1, n, pct)
+	}
+	info := fmt.Sprintf(" %s  %s ", p.enc, pos)
+	if p.info == nil {
+		info = " loading… "
+	}
+	if p.wrap {
+		info += "[wrap] "
+	}
+	if p.follow {
+		info += "[follow] "
+	}
+	drawText(s, 0, 0, " "+displayPath(p.path), barStyle, sw)
+	infoW := len([]rune(info))
+	drawText(s, sw-infoW, 0, info, barStyle, infoW)
+
+	row := 0
+	if p.dropped && p.top == 0 {
+		drawText(s, 0, 1, fmt.Sprintf("(only the last %s is kept)", humanSize(pagerMaxBytes)), grayStyle, sw)
+		row++
+	}
+	for i := p.top; row < p.h && i < len(p.lines); i++ {
+		r := []rune(p.line(i))
+		// вхождения запроса в этой строке
+		marks := make([]tcell.Style, len(r))
+		hit := make([]bool, len(r))
+		qlen := utf8.RuneCountInString(p.query)
+		for _, c := range matchesIn(string(r), p.query) {
+			style := matchStyle
+			if i == p.matchLine && c == p.matchCol {
+				style = currentStyle
+			}
+			for k := c; k < c+qlen && k < len(r); k++ {
+				marks[k], hit[k] = style, true
+			}
+		}
+		start := 0
+		if !p.wrap {
+			start = p.left
+		}
+		for ; row < p.h; start += sw {
+			for x := 0; x < sw && start+x < len(r); x++ {
+				style := textStyle
+				if hit[start+x] {
+					style = marks[start+x]
+				}
+				s.SetContent(x, 1+row, r[start+x], nil, style)
+			}
+			row++
+			if !p.wrap || start+sw >= len(r) {
+				break
+			}
+		}
+	}
+	if p.cut && row < p.h {
+		drawText(s, 0, 1+row, fmt.Sprintf("(only the first %s shown)", humanSize(pagerMaxBytes)), grayStyle, sw)
+		row++
+	}
+	for ; row < p.h; row++ {
+		s.SetContent(0, 1+row, '~', nil, grayStyle)
+	}
+
+	bottom := p.msg
+	if bottom == "" {
+		bottom = "/ ? search  n N next/prev  w wrap  F follow  e encoding  g G top/end  q quit"
+	}
+	drawText(s, 0, sh-1, " "+bottom, grayStyle, sw)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encodeTest(t *testing.T, enc, s string) []byte {
+	t.Helper()
+	var b []byte
+	var err error
+	switch enc {
+	case "utf-16le":
+		b, err = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(s))
+	case "utf-16be":
+		b, err = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(s))
+	case "windows-1251":
+		b, err = charmap.Windows1251.NewEncoder().Bytes([]byte(s))
+	case "koi8-r":
+		b, err = charmap.KOI8R.NewEncoder().Bytes([]byte(s))
+	default:
+		b = []byte(s)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	const ru = "Съешь же ещё этих мягких французских булок, да выпей чаю.\n"
+	const en = "The quick brown fox jumps over the lazy dog.\n"
+	tests := []struct {
+		name string
+		head []byte
+		enc  string
+		bom  int
+		ok   bool
+	}{
+		{"empty", nil, "utf-8", 0, true},
+		{"ascii", []byte(en), "utf-8", 0, true},
+		{"utf-8", []byte(ru), "utf-8", 0, true},
+		{"utf-8 bom", append([]byte{0xef, 0xbb, 0xbf}, ru...), "utf-8", 3, true},
+		{"utf-16le bom", append([]byte{0xff, 0xfe}, encodeTest(t, "utf-16le", ru)...), "utf-16le", 2, true},
+		{"utf-16be bom", append([]byte{0xfe, 0xff}, encodeTest(t, "utf-16be", en)...), "utf-16be", 2, true},
+		{"utf-16le", encodeTest(t, "utf-16le", en), "utf-16le", 0, true},
+		{"utf-16le cyrillic", encodeTest(t, "utf-16le", ru), "utf-16le", 0, true},
+		{"utf-16be", encodeTest(t, "utf-16be", ru), "utf-16be", 0, true},
+		{"windows-1251", encodeTest(t, "windows-1251", ru), "windows-1251", 0, true},
+		{"koi8-r", encodeTest(t, "koi8-r", ru), "koi8-r", 0, true},
+		{"ansi colours", []byte("\x1b[31mred\x1b[0m\n"), "utf-8", 0, true},
+		{"binary zeros", []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0}, "", 0, false},
+		{"binary controls", []byte{0xc8, 0x01, 0x02, 0xe0, 0x03, 0xd0}, "", 0, false},
+	}
+	for _, tt := range tests {
+		enc, bom, ok := detectEncoding(tt.head)
+		if ok != tt.ok || (ok && (enc != tt.enc || bom != tt.bom)) {
+			t.Errorf("%s: detectEncoding = %q, %d, %v; want %q, %d, %v", tt.name, enc, bom, ok, tt.enc, tt.bom, tt.ok)
+		}
+	}
+}
+
+func TestMatchesIn(t *testing.T) {
+	tests := []struct {
+		line, query string
+		want        []int
+	}{
+		{"abcabc", "bc", []int{1, 4}},
+		{"aaaa", "aa", []int{0, 2}},
+		{"Error error ERROR", "error", []int{0, 6, 12}},
+		{"Error error ERROR", "Error", []int{0}},
+		{"привет, Привет", "привет", []int{0, 8}},
+		{"ёжик", "жик", []int{1}},
+		// строчная İ — две руны, позиции после неё не должны сдвигаться
+		{"İstanbul istanbul", "stan", []int{1, 10}},
+		{"İİ ab AB", "ab", []int{3, 6}},
+		{"abc", "", nil},
+		{"abc", "x", nil},
+		{"", "a", nil},
+	}
+	for _, tt := range tests {
+		if got := matchesIn(tt.line, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("matchesIn(%q, %q) = %v, want %v", tt.line, tt.query, got, tt.want)
+		}
+	}
+}
+
+// decode должен собирать символы, разрезанные границей чтения
+func TestPagerDecodeSplit(t *testing.T) {
+	tests := []struct {
+		enc, text string
+	}{
+		{"utf-8", "строка\n😀 emoji\nend"},
+		{"utf-16le", "строка\n😀 emoji\nend"},
+		{"utf-16be", "строка\n😀 emoji\nend"},
+		{"koi8-r", "строка\nend"},
+	}
+	for _, tt := range tests {
+		data := encodeTest(t, tt.enc, tt.text)
+		for cut := 0; cut <= len(data); cut++ {
+			p := &pager{enc: tt.enc}
+			got := p.decode(data[:cut])
+			got += p.decode(data[cut:])
+			if got != tt.text || len(p.pending) != 0 {
+				t.Errorf("%s cut at %d: %q (pending %v), want %q", tt.enc, cut, got, p.pending, tt.text)
+				break
+			}
+		}
+	}
+}
+
+func TestPollPager(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	first := readPager(path, "", true)
+	if first.err != nil || !slices.Equal(first.parts, []string{"one", "two", ""}) {
+		t.Fatalf("readPager = %q, %v", first.parts, first.err)
+	}
+
+	// без изменений
+	if ev := pollPager(path, first.info, first.enc, first.size, nil); ev.err != nil || ev.reload || ev.parts != nil || ev.size != first.size {
+		t.Errorf("unchanged file: %+v", ev)
+	}
+
+	// дописанное читается с прошлого места
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("three\n")
+	f.Close()
+	ev := pollPager(path, first.info, first.enc, first.size, nil)
+	if ev.err != nil || ev.reload || !slices.Equal(ev.parts, []string{"three", ""}) || ev.size != 14 {
+		t.Errorf("appended: reload %v parts %q size %d err %v", ev.reload, ev.parts, ev.size, ev.err)
+	}
+
+	// ротация: на месте файла новый, длиннее старого
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("rotated and longer than before\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ev = pollPager(path, first.info, first.enc, 14, nil)
+	if ev.err != nil || !ev.reload || !slices.Equal(ev.parts, []string{"rotated and longer than before", ""}) {
+		t.Errorf("rotated: reload %v parts %q err %v", ev.reload, ev.parts, ev.err)
+	}
+
+	// усечение того же файла
+	if err := os.Truncate(path, 3); err != nil {
+		t.Fatal(err)
+	}
+	ev = pollPager(path, ev.info, ev.enc, ev.size, nil)
+	if ev.err != nil || !ev.reload || !slices.Equal(ev.parts, []string{"rot"}) {
+		t.Errorf("truncated: reload %v parts %q err %v", ev.reload, ev.parts, ev.err)
+	}
+}
+
+// без слежения большой файл читается с начала и помечается обрезанным
+func TestReadPagerCut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.log")
+	line := strings.Repeat("x", 1023) + "\n"
+	if err := os.WriteFile(path, []byte(strings.Repeat(line, pagerMaxBytes/len(line)+1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ev := readPager(path, "", false)
+	if ev.err != nil || !ev.cut || ev.dropped || ev.size != pagerMaxBytes {
+		t.Errorf("head: cut %v dropped %v size %d err %v", ev.cut, ev.dropped, ev.size, ev.err)
+	}
+	ev = readPager(path, "", true)
+	if ev.err != nil || ev.cut || !ev.dropped {
+		t.Errorf("follow: cut %v dropped %v err %v", ev.cut, ev.dropped, ev.err)
+	}
+}
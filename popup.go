@@ -0,0 +1,89 @@
+package main
+
+import "github.com/gdamore/tcell/v2"
+
+// ---------------- list popup ----------------
+// listPopup — всплывающий список с курсором: история, варианты дополнения и т.п.
+type listPopup struct {
+	title  string
+	items  []string
+	cursor int
+	offset int
+}
+
+const listPopupRows = 15
+
+func (lp *listPopup) move(delta int) {
+	lp.cursor += delta
+	if lp.cursor >= len(lp.items) {
+		lp.cursor = len(lp.items) - 1
+	}
+	if lp.cursor < 0 {
+		lp.cursor = 0
+	}
+	if lp.cursor < lp.offset {
+		lp.offset = lp.cursor
+	}
+	if lp.cursor >= lp.offset+listPopupRows {
+		lp.offset = lp.cursor - listPopupRows + 1
+	}
+}
+
+// listPopupKey обрабатывает перемещение по списку; true — клавиша использована
+func listPopupKey(lp *listPopup, ev *tcell.EventKey) bool {
+	switch ev.Key() {
+	case tcell.KeyUp:
+		lp.move(-1)
+	case tcell.KeyDown:
+		lp.move(1)
+	case tcell.KeyPgUp:
+		lp.move(-listPopupRows)
+	case tcell.KeyPgDn:
+		lp.move(listPopupRows)
+	case tcell.KeyHome:
+		lp.move(-len(lp.items))
+	case tcell.KeyEnd:
+		lp.move(len(lp.items))
+	default:
+		return false
+	}
+	return true
+}
+
+func drawListPopup(s tcell.Screen, lp *listPopup) {
+	sw, sh := s.Size()
+	w := sw * 2 / 3
+	if w < 40 {
+		w = 40
+	}
+	if w > sw {
+		w = sw
+	}
+	rows := len(lp.items)
+	if rows > listPopupRows {
+		rows = listPopupRows
+	}
+	if rows == 0 {
+		rows = 1
+	}
+	h := rows + 4
+	x := (sw - w) / 2
+	y := (sh - h) / 2
+
+	drawBox(s, x, y, w, h)
+	headerStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	drawText(s, x+2, y+1, lp.title, headerStyle, w-4)
+	if len(lp.items) == 0 {
+		drawText(s, x+2, y+3, "(empty)", textStyle.Foreground(tcell.ColorGray), w-4)
+		return
+	}
+	for i := 0; i < rows && lp.offset+i < len(lp.items); i++ {
+		idx := lp.offset + i
+		style := textStyle
+		if idx == lp.cursor {
+			style = style.Reverse(true)
+		}
+		drawText(s, x+2, y+3+i, lp.items[idx], style, w-4)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- preview ----------------
+// Просмотр элемента под курсором: содержимое каталога или начало текстового
+// файла с подсветкой синтаксиса. Читается и размечается в фоне, результат
+// приходит в канал событий как *previewEvent; устаревшие ответы отбрасываются
+// по поколению, так что быстрый проход по большим файлам не тормозит список.
+const (
+	previewMaxBytes   = 64 << 10 // столько читаем из начала файла
+	previewMaxEntries = 1000     // столько записей каталога показываем
+)
+
+type previewData struct {
+	path    string
+	dir     bool
+	entries []Entry     // для каталога
+	lines   []string    // для текстового файла, табуляции уже раскрыты
+	hl      [][]hlClass // подсветка строк по рунам, nil — язык не распознан
+	lang    string
+	note    string // вместо содержимого: ошибка, пустой каталог и т.п.
+
+	img *imageInfo // картинка, см. image.go
+
+	// разобранный формат, см. structured.go
+	banner  string      // сообщение над текстом: ошибка разбора и т.п.
+	errLine int         // строка с ошибкой разбора, с 1
+	json    *jsonNode   // дерево JSON
+	nodes   []*jsonNode // узел каждой строки JSON
+	cursor  int         // строка JSON под курсором просмотра
+
+	// двоичный файл: окно chunk с позиции offset, см. hexview.go
+	hex    bool
+	size   int64
+	offset int64
+	chunk  []byte
+}
+
+type previewEvent struct {
+	when time.Time
+	gen  int
+	data *previewData
+}
+
+func (e *previewEvent) When() time.Time { return e.when }
+
+var previewGen int
+
+// startPreview запускает чтение path в фоне и возвращает его поколение
+func startPreview(path string, dir bool, tabWidth int, out chan<- tcell.Event) int {
+	previewGen++
+	gen := previewGen
+	// ключи имён считаем в фоне отдельным сортировщиком, как при загрузке каталога
+	var keyer *entryKeyer
+	if dir && nameCollator != nil {
+		keyer = newEntryKeyer(newNameCollator(), collationGen)
+	}
+	go func() {
+		out <- &previewEvent{when: time.Now(), gen: gen, data: loadPreview(path, dir, keyer, tabWidth)}
+	}()
+	return gen
+}
+
+// arrange сортирует записи каталога; вызывается из горутины интерфейса, как и
+// applyDirLoad, потому что порядок каталогов и правила сравнения меняются в ней
+func (d *previewData) arrange() {
+	if !d.dir || d.note != "" {
+		return
+	}
+	d.entries = arrangeEntries(d.entries, sortFor(d.path))
+	if len(d.entries) == 0 {
+		d.note = "(empty)"
+	}
+}
+
+func loadPreview(path string, dir bool, keyer *entryKeyer, tabWidth int) *previewData {
+	d := &previewData{path: path, dir: dir}
+	if isRemote(path) {
+		d.note = "(no preview for remote files)"
+		return d
+	}
+	if dir {
+		f, err := os.Open(path)
+		if err != nil {
+			d.note = err.Error()
+			return d
+		}
+		defer f.Close()
+		des, _ := f.ReadDir(previewMaxEntries)
+		entries := make([]Entry, 0, len(des))
+		for _, de := range des {
+			e := newEntry(path, de)
+			keyer.fill(&e)
+			entries = append(entries, e)
+		}
+		d.entries = entries
+		return d
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		d.note = err.Error()
+		return d
+	}
+	defer f.Close()
+	buf := make([]byte, previewMaxBytes)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		d.note = err.Error()
+		return d
+	}
+	buf = buf[:n]
+	if img, err := loadImage(path, buf); err != nil {
+		d.note = err.Error()
+		return d
+	} else if img != nil {
+		d.img = img
+		return d
+	}
+	if isBinary(buf) {
+		d.hex, d.size, d.chunk = true, fileSize(f), buf
+		return d
+	}
+	truncated := n == previewMaxBytes
+	kind, sniffed := structuredKind(path, buf)
+	text := strings.ReplaceAll(string(buf), "\r\n", "\n")
+	switch kind {
+	case "json":
+		if previewJSON(d, f, buf, truncated) {
+			return d
+		}
+	case "csv", "tsv":
+		if previewTable(d, text, kind, truncated) {
+			return d
+		}
+	}
+	if sniffed {
+		// содержимое было только похоже на формат — показываем обычный текст без ошибки
+		kind, d.banner, d.errLine = "", "", 0
+	}
+	if !truncated {
+		text = strings.TrimSuffix(text, "\n")
+	}
+	d.lines = strings.Split(text, "\n")
+	if truncated && len(d.lines) > 1 {
+		// последняя строка могла оборваться на середине
+		d.lines = d.lines[:len(d.lines)-1]
+	}
+	for i, line := range d.lines {
+		d.lines[i] = expandTabs(line, max(tabWidth, 1))
+	}
+	if kind == "markdown" {
+		previewMarkdown(d)
+		return d
+	}
+	syn := detectSyntax(path, d.lines[0])
+	if syn == nil && kind == "json" {
+		syn = syntaxNamed("json")
+	}
+	if syn != nil {
+		d.lang = syn.name
+		d.hl = highlightLines(d.lines, syn)
+	}
+	// строку с ошибкой разбора выделяем целиком
+	if i := d.errLine - 1; i >= 0 && i < len(d.lines) {
+		if d.hl == nil {
+			d.hl = make([][]hlClass, len(d.lines))
+		}
+		d.hl[i] = make([]hlClass, len([]rune(d.lines[i])))
+		for k := range d.hl[i] {
+			d.hl[i][k] = hlError
+		}
+	}
+	return d
+}
+
+// isBinary считает файл двоичным, если в начале есть NUL или это не UTF-8
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	// обрезанный на границе символ не считается ошибкой
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return !utf8.Valid(head)
+}
+
+func fileSize(f *os.File) int64 {
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// expandTabs заменяет табуляции пробелами до ближайшей позиции, кратной width
+func expandTabs(line string, width int) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - col%width
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// drawPreview рисует рамку с именем элемента и его содержимое, прокрученное
+// до top: строки текста и каталога или смещение в дампе. При pixels место
+// картинки остаётся пустым — её выведет termGraphics.
+func drawPreview(s tcell.Screen, x, y, w, h int, d *previewData, top int64, pixels bool) {
+	// пустая неактивная панель даёт такую же серую рамку, как у списков
+	drawPanel(s, &Panel{x: x, y: y, w: w, h: h, border: true})
+	borderStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	if d == nil {
+		return
+	}
+	title := fmt.Sprintf(" %s ", baseName(d.path))
+	drawText(s, x+1, y, title, borderStyle, min(len([]rune(title)), w-2))
+
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	grayStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	rows := h - 2
+	if d.note != "" {
+		drawText(s, x+1, y+1, d.note, grayStyle, w-2)
+		return
+	}
+	if d.hex {
+		drawHex(s, x, y, w, h, d, top)
+		return
+	}
+	if d.img != nil {
+		info := fmt.Sprintf(" %s %d×%d ", d.img.format, d.img.w, d.img.h)
+		drawText(s, x+w-2-len([]rune(info)), y+h-1, info, borderStyle, len([]rune(info)))
+		if !pixels {
+			drawImage(s, x+1, y+1, w-2, h-2, d.img)
+		}
+		return
+	}
+	if d.dir {
+		for i := 0; i < rows && int(top)+i < len(d.entries); i++ {
+			e := &d.entries[int(top)+i]
+			style := grayStyle
+			if e.isDir() {
+				style = textStyle
+			}
+			drawText(s, x+1, y+1+i, e.name, style, w-2)
+		}
+		return
+	}
+	if d.lang != "" {
+		lang := fmt.Sprintf(" %s ", d.lang)
+		drawText(s, x+w-2-len([]rune(lang)), y+h-1, lang, borderStyle, len([]rune(lang)))
+	}
+	if d.banner != "" {
+		errStyle := tcell.StyleDefault.Foreground(tcell.ColorRed)
+		drawText(s, x+1, y+1, d.banner, errStyle, w-2)
+		y++
+		rows--
+	}
+
+	// номера строк — в колонке слева; продолжения перенесённой строки без номера
+	gutter := 0
+	if config.LineNumbers {
+		gutter = len(strconv.Itoa(len(d.lines))) + 1
+	}
+	textW := w - 2 - gutter
+	if textW <= 0 {
+		return
+	}
+	row := 0
+	for i := int(top); row < rows && i < len(d.lines); i++ {
+		r := []rune(d.lines[i])
+		for start := 0; row < rows; start += textW {
+			if gutter > 0 && start == 0 {
+				num := fmt.Sprintf("%*d", gutter-1, i+1)
+				drawText(s, x+1, y+1+row, num, grayStyle, gutter-1)
+			}
+			for j := start; j < len(r) && j < start+textW; j++ {
+				style := textStyle
+				if d.hl != nil && d.hl[i] != nil {
+					style = hlStyles[d.hl[i][j]]
+				}
+				if d.json != nil && i == d.cursor {
+					style = style.Reverse(true)
+				}
+				s.SetContent(x+1+gutter+j-start, y+1+row, r[j], nil, style)
+			}
+			row++
+			if !config.PreviewWrap || start+textW >= len(r) {
+				break
+			}
+		}
+	}
+}
+
+// drawParentColumn рисует колонку родительского каталога: она не в фокусе,
+// но текущий каталог в ней выделен
+func drawParentColumn(s tcell.Screen, p *Panel) {
+	drawPanel(s, p)
+	e := selectedEntry(p)
+	if e == nil {
+		return
+	}
+	row := p.cursor - p.offset
+	if row < 0 || row >= visibleCount(p) {
+		return
+	}
+	style := tcell.StyleDefault.Foreground(tcell.ColorGray).Reverse(true)
+	drawText(s, p.x+1, p.y+1+row, e.name, style, p.w-2)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- attribute find ----------------
+// Запрос — условия через пробел, все должны выполняться:
+//
+//	size>100M  size<=4k        размер (B, K, M, G, T по 1024)
+//	mtime<7d   ctime>2024-01-01 возраст (s, m, h, d, w, y) или дата
+//	ext:log,txt type:f|d|l     расширение и тип
+//	name:*.go  name:~"^core"   имя по шаблону или регулярному выражению
+//	path:~/tmp/  owner:root    путь от корня поиска, владелец
+//	!ext:tmp   core            отрицание; просто слово — часть имени
+type entryTest func(e *Entry, rel string) bool
+
+type findQuery struct {
+	text  string
+	tests []entryTest
+}
+
+func (q *findQuery) match(e *Entry, rel string) bool {
+	for _, t := range q.tests {
+		if !t(e, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitQuery делит запрос на слова; двойные кавычки сохраняют пробелы
+func splitQuery(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inQuote, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			started = true
+		case unicode.IsSpace(r) && !inQuote:
+			if started {
+				words = append(words, cur.String())
+				cur.Reset()
+				started = false
+			}
+		default:
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if started {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+func parseQuery(s string) (*findQuery, error) {
+	words, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	q := &findQuery{text: s}
+	for _, w := range words {
+		negate := false
+		if len(w) > 1 && w[0] == '!' {
+			negate = true
+			w = w[1:]
+		}
+		t, err := parseTerm(w)
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			inner := t
+			t = func(e *Entry, rel string) bool { return !inner(e, rel) }
+		}
+		q.tests = append(q.tests, t)
+	}
+	return q, nil
+}
+
+var termRe = regexp.MustCompile(`^([a-z]+)(<=|>=|!=|<|>|=|:)(.*)$`)
+
+func parseTerm(w string) (entryTest, error) {
+	m := termRe.FindStringSubmatch(w)
+	if m == nil {
+		// просто слово — подстрока имени без учёта регистра
+		sub := strings.ToLower(w)
+		return func(e *Entry, rel string) bool {
+			return strings.Contains(strings.ToLower(path.Base(rel)), sub)
+		}, nil
+	}
+	key, op, val := m[1], m[2], m[3]
+	if op == "=" {
+		op = ":"
+	}
+	switch key {
+	case "size":
+		n, err := parseSize(val)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry, rel string) bool {
+			return !e.isDir() && compareOp(cmpInt64(e.size, n), op)
+		}, nil
+
+	case "mtime", "ctime":
+		field := func(e *Entry) time.Time { return e.modTime }
+		if key == "ctime" {
+			field = func(e *Entry) time.Time { return e.ctime }
+		}
+		if d, err := parseAge(val); err == nil {
+			// mtime<7d — изменён меньше 7 дней назад
+			return func(e *Entry, rel string) bool {
+				return compareOp(cmpInt64(int64(time.Since(field(e))), int64(d)), op)
+			}, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", val, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s value %q", key, val)
+		}
+		// с датой знаки читаются как "раньше/позже"
+		return func(e *Entry, rel string) bool {
+			return compareOp(field(e).Compare(t), op)
+		}, nil
+
+	case "ext":
+		if op != ":" {
+			break
+		}
+		exts := strings.Split(strings.ToLower(val), ",")
+		for i := range exts {
+			exts[i] = strings.TrimPrefix(exts[i], ".")
+		}
+		return func(e *Entry, rel string) bool {
+			ext := extOf(e)
+			for _, x := range exts {
+				if x == ext {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case "type":
+		if op != ":" {
+			break
+		}
+		switch val {
+		case "f":
+			return func(e *Entry, rel string) bool { return e.kind == kindFile }, nil
+		case "d":
+			return func(e *Entry, rel string) bool { return e.isDir() }, nil
+		case "l":
+			return func(e *Entry, rel string) bool { return e.mode&fs.ModeSymlink != 0 }, nil
+		}
+		return nil, fmt.Errorf("bad type %q (use f, d or l)", val)
+
+	case "name", "path":
+		if op != ":" {
+			break
+		}
+		subject := func(rel string) string { return path.Base(rel) }
+		if key == "path" {
+			subject = func(rel string) string { return rel }
+		}
+		var re *regexp.Regexp
+		var err error
+		if strings.HasPrefix(val, "~") {
+			expr := val[1:]
+			if !hasUpper(expr) {
+				expr = "(?i)" + expr
+			}
+			re, err = regexp.Compile(expr)
+		} else {
+			re, err = regexp.Compile("(?i)^" + globToRegexp(val) + "$")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry, rel string) bool { return re.MatchString(subject(rel)) }, nil
+
+	case "owner", "group":
+		if op != ":" {
+			break
+		}
+		return func(e *Entry, rel string) bool {
+			if key == "group" {
+				return e.group == val
+			}
+			return e.owner == val
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown field %q", key)
+	}
+	return nil, fmt.Errorf("operator %q is not supported for %s", op, key)
+}
+
+// compareOp применяет оператор к результату сравнения c (-1, 0, 1)
+func compareOp(c int, op string) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "!=":
+		return c != 0
+	}
+	return c == 0
+}
+
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(s, "b"), "B"))
+	mult := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			mult = int64(1) << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty age")
+	}
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("bad age %q", s)
+	}
+	f, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(f * float64(unit)), nil
+}
+
+// findAsync обходит дерево под root и отдаёт подходящие записи панели как
+// обычную загрузку каталога. Имена записей — пути от root, так что entryPath
+// и файловые операции работают с ними без изменений.
+func findAsync(p *Panel, gen int, root string, q *findQuery, hidden bool, keyer *entryKeyer, cancel <-chan struct{}, out chan<- tcell.Event) {
+	send := func(entries []Entry, done bool) bool {
+		ev := &dirLoadEvent{when: time.Now(), panel: p, gen: gen, entries: entries, done: done}
+		select {
+		case out <- ev:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	var batch []Entry
+	last := time.Now()
+	total := 0
+	stopped := false
+	walkTree(root, hidden, cancel, func(rel string, d fs.DirEntry) bool {
+		e := newEntry(joinPath(root, filepath.FromSlash(path.Dir(rel))), d)
+		e.name = filepath.FromSlash(rel)
+		if q.match(&e, rel) {
+			keyer.fill(&e)
+			batch = append(batch, e)
+			total++
+		}
+		if total >= finderMaxPaths {
+			return false
+		}
+		// пустые порции тоже отправляем: они показывают, что обход не завис
+		if time.Since(last) >= loadFlushTime {
+			if !send(batch, false) {
+				stopped = true
+				return false
+			}
+			batch = nil
+			last = time.Now()
+		}
+		return true
+	})
+	if !stopped {
+		send(batch, true)
+	}
+}
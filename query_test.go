@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"100", 100, false},
+		{"100b", 100, false},
+		{"100B", 100, false},
+		{"4k", 4 << 10, false},
+		{"4K", 4 << 10, false},
+		{"4kb", 4 << 10, false},
+		{"1.5M", 3 << 19, false},
+		{"2G", 2 << 30, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"b", 0, true},
+		{"K", 0, true},
+		{"-1", 0, true},
+		{"10X", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30s", 30 * time.Second, false},
+		{"15m", 15 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"7d", 7 * day, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w", 14 * day, false},
+		{"1y", 365 * day, false},
+		{"", 0, true},
+		{"7", 0, true},
+		{"d", 0, true},
+		{"7x", 0, true},
+		{"2024-01-01", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	now := time.Now()
+	file := func(name string, size int64, age time.Duration) *Entry {
+		return &Entry{name: name, kind: kindFile, size: size, modTime: now.Add(-age), ctime: now.Add(-age), owner: "root", group: "wheel"}
+	}
+	dir := &Entry{name: "src", kind: kindDir, mode: fs.ModeDir | 0755, modTime: now, owner: "user"}
+	link := &Entry{name: "ln", kind: kindFile, mode: fs.ModeSymlink | 0777, modTime: now}
+	big := file("video.MKV", 200<<20, time.Hour)
+	small := file("notes.txt", 3<<10, 30*24*time.Hour)
+	old := file("core.log", 10, 400*24*time.Hour)
+
+	tests := []struct {
+		query string
+		e     *Entry
+		rel   string
+		want  bool
+	}{
+		{"size>100M", big, "video.MKV", true},
+		{"size>100M", small, "notes.txt", false},
+		{"size<=3k", small, "notes.txt", true},
+		{"size=3K", small, "notes.txt", true},
+		{"size!=3K", small, "notes.txt", false},
+		{"size>0", dir, "src", false}, // у каталогов размера нет
+		{"mtime<7d", big, "video.MKV", true},
+		{"mtime<7d", small, "notes.txt", false},
+		{"mtime>1y", old, "core.log", true},
+		{"ctime>=2w", small, "notes.txt", true},
+		{"mtime<2000-01-01", old, "core.log", false},
+		{"mtime>2000-01-01", old, "core.log", true},
+		{"ext:mkv", big, "video.MKV", true},
+		{"ext:txt,log", old, "core.log", true},
+		{"ext:.txt", small, "notes.txt", true},
+		{"ext:txt", dir, "src", false},
+		{"type:f", small, "notes.txt", true},
+		{"type:d", dir, "src", true},
+		{"type:d", small, "notes.txt", false},
+		{"type:l", link, "ln", true},
+		{"name:*.txt", small, "a/b/notes.txt", true},
+		{"name:*.txt", small, "a/notes.txt.bak", false},
+		{"name:NOTES*", small, "notes.txt", true},
+		{`name:~^core\.`, old, "x/core.log", true},
+		{"name:~^Core", old, "core.log", false}, // с заглавной регистр учитывается
+		{"path:~^a/b/", small, "a/b/notes.txt", true},
+		{"path:a/*/notes.txt", small, "a/b/notes.txt", true},
+		{"owner:root", small, "notes.txt", true},
+		{"group:wheel", small, "notes.txt", true},
+		{"owner:root", dir, "src", false},
+		{"core", old, "x/core.log", true},
+		{"CORE", old, "x/core.log", true},
+		{"core", small, "core/notes.txt", false}, // слово ищется только в имени
+		{"!ext:log", old, "core.log", false},
+		{"!ext:log", small, "notes.txt", true},
+		{"type:f size>1k !name:*.mkv", small, "notes.txt", true},
+		{"type:f size>1k !name:*.mkv", big, "video.MKV", false},
+		{`name:"my file*"`, file("my file.txt", 1, 0), "my file.txt", true},
+		{"!", small, "notes.txt", false}, // одиночный '!' — просто слово
+	}
+	for _, tt := range tests {
+		q, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q) error: %v", tt.query, err)
+			continue
+		}
+		if got := q.match(tt.e, tt.rel); got != tt.want {
+			t.Errorf("parseQuery(%q).match(%q) = %v, want %v", tt.query, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, q := range []string{
+		"",
+		"   ",
+		`name:"open`,
+		"size>lots",
+		"mtime<soon",
+		"type:x",
+		"ext>txt",
+		"color:red",
+		"name:~(",
+	} {
+		if _, err := parseQuery(q); err == nil {
+			t.Errorf("parseQuery(%q) succeeded, want error", q)
+		}
+	}
+}
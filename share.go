@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// ---------------- share server ----------------
+// shareServer раздаёт каталог по HTTP/WebDAV; без writable разрешено только чтение.
+// По умолчанию слушает только 127.0.0.1, всю сеть — лишь при public. Каждый
+// запрос проверяется по паролю basic auth — случайному токену раздачи.
+type shareServer struct {
+	srv      *http.Server
+	root     *os.Root
+	writable bool
+	public   bool
+	token    string
+	url      string
+}
+
+const shareUser = "walker"
+
+func startShare(dir string, port int, writable, public bool) (*shareServer, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	host := "127.0.0.1"
+	if public {
+		host = ""
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+	if err != nil {
+		root.Close()
+		return nil, err
+	}
+	sh := &shareServer{root: root, writable: writable, public: public, token: hex.EncodeToString(token)}
+	sh.srv = &http.Server{Handler: sh}
+	if public {
+		host = localIP()
+	}
+	sh.url = fmt.Sprintf("http://%s:%s@%s/", shareUser, sh.token,
+		net.JoinHostPort(host, fmt.Sprint(ln.Addr().(*net.TCPAddr).Port)))
+	go sh.srv.Serve(ln)
+	return sh, nil
+}
+
+// authorized сверяет пароль basic auth с токеном за постоянное время
+func (sh *shareServer) authorized(r *http.Request) bool {
+	_, pass, ok := r.BasicAuth()
+	return ok && subtle.ConstantTimeCompare([]byte(pass), []byte(sh.token)) == 1
+}
+
+func (sh *shareServer) stop() {
+	sh.srv.Close()
+	sh.root.Close()
+}
+
+// localIP возвращает первый внешний IPv4-адрес, чтобы ссылкой можно было поделиться
+func localIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "localhost"
+	}
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && !ipn.IP.IsLoopback() && ipn.IP.To4() != nil {
+			return ipn.IP.String()
+		}
+	}
+	return "localhost"
+}
+
+// relName переводит путь запроса в имя внутри раздаваемого каталога
+func relName(p string) string {
+	rel := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if rel == "" {
+		return "."
+	}
+	return rel
+}
+
+func (sh *shareServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sh.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="go-walker share"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := relName(r.URL.Path)
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, PUT, DELETE, MKCOL, MOVE, COPY")
+		return
+	case http.MethodGet, http.MethodHead:
+		http.FileServerFS(sh.root.FS()).ServeHTTP(w, r)
+		return
+	case "PROPFIND":
+		sh.propfind(w, r, name)
+		return
+	case http.MethodPut, http.MethodDelete, "MKCOL", "MOVE", "COPY":
+		if !sh.writable {
+			http.Error(w, "read-only share", http.StatusForbidden)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	status := http.StatusCreated
+	switch r.Method {
+	case http.MethodPut:
+		err = sh.put(name, r.Body)
+	case http.MethodDelete:
+		if _, err = sh.root.Lstat(name); err == nil {
+			err = sh.root.RemoveAll(name)
+		}
+		status = http.StatusNoContent
+	case "MKCOL":
+		err = sh.root.Mkdir(name, 0755)
+		if errors.Is(err, fs.ErrExist) {
+			status = http.StatusMethodNotAllowed
+			err = nil
+		}
+	case "MOVE", "COPY":
+		status, err = sh.transfer(r, name)
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(status)
+	}
+}
+
+func (sh *shareServer) put(name string, body io.Reader) error {
+	f, err := sh.root.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (sh *shareServer) transfer(r *http.Request, name string) (int, error) {
+	u, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || u.Path == "" {
+		return http.StatusBadRequest, errors.New("bad Destination header")
+	}
+	dst := relName(u.Path)
+	if _, err := sh.root.Lstat(dst); err == nil {
+		if r.Header.Get("Overwrite") == "F" {
+			return http.StatusPreconditionFailed, nil
+		}
+		if err := sh.root.RemoveAll(dst); err != nil {
+			return 0, err
+		}
+	}
+	if r.Method == "MOVE" {
+		return http.StatusCreated, sh.root.Rename(name, dst)
+	}
+	return http.StatusCreated, sh.copy(name, dst)
+}
+
+func (sh *shareServer) copy(src, dst string) error {
+	info, err := sh.root.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		in, err := sh.root.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return sh.put(dst, in)
+	}
+	if err := sh.root.Mkdir(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	entries, err := fs.ReadDir(sh.root.FS(), src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := sh.copy(path.Join(src, e.Name()), path.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sh *shareServer) propfind(w http.ResponseWriter, r *http.Request, name string) {
+	info, err := sh.root.Stat(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<D:multistatus xmlns:D="DAV:">`)
+	writeProp := func(p string, fi fs.FileInfo) {
+		href := (&url.URL{Path: p}).EscapedPath()
+		rt, size := "", ""
+		if fi.IsDir() {
+			href = strings.TrimSuffix(href, "/") + "/"
+			rt = "<D:collection/>"
+		} else {
+			size = fmt.Sprintf("<D:getcontentlength>%d</D:getcontentlength>", fi.Size())
+		}
+		b.WriteString("<D:response><D:href>")
+		xml.EscapeText(&b, []byte(href))
+		fmt.Fprintf(&b, "</D:href><D:propstat><D:prop><D:resourcetype>%s</D:resourcetype>%s"+
+			"<D:getlastmodified>%s</D:getlastmodified></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>",
+			rt, size, fi.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	self := "/"
+	if name != "." {
+		self += name
+	}
+	writeProp(self, info)
+	if info.IsDir() && r.Header.Get("Depth") != "0" {
+		entries, _ := fs.ReadDir(sh.root.FS(), name)
+		for _, e := range entries {
+			if fi, err := e.Info(); err == nil {
+				writeProp(path.Join(self, e.Name()), fi)
+			}
+		}
+	}
+	b.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(207)
+	io.WriteString(w, b.String())
+}
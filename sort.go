@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- sorting ----------------
+type sortOrder struct {
+	Mode      string `json:"mode"` // name, ext, size, mtime, ctime, type
+	Desc      bool   `json:"desc"`
+	DirsFirst bool   `json:"dirsFirst"`
+}
+
+var defaultSort = sortOrder{Mode: "name", DirsFirst: true}
+
+// порядок, выбранный для каталогов; сохраняется между запусками
+var dirSorts = map[string]sortOrder{}
+
+func sortsFile() string {
+	return filepath.Join(homeDir, ".myfm_sort.json")
+}
+
+func loadSorts() {
+	data, err := os.ReadFile(sortsFile())
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &dirSorts)
+}
+
+func saveSorts() {
+	data, _ := json.MarshalIndent(dirSorts, "", "  ")
+	_ = os.WriteFile(sortsFile(), data, 0644)
+}
+
+func sortFor(dir string) sortOrder {
+	if o, ok := dirSorts[dir]; ok {
+		return o
+	}
+	return defaultSort
+}
+
+// setSort запоминает порядок для каталога; порядок по умолчанию в файл не пишем
+func setSort(dir string, o sortOrder) {
+	if o == defaultSort {
+		delete(dirSorts, dir)
+	} else {
+		dirSorts[dir] = o
+	}
+	saveSorts()
+}
+
+func (o sortOrder) String() string {
+	arrow := "↑"
+	if o.Desc {
+		arrow = "↓"
+	}
+	return o.Mode + arrow
+}
+
+// bucket группирует элементы при DirsFirst: скрытые каталоги, каталоги,
+// файлы, скрытые файлы. Порядок групп от направления сортировки не зависит.
+func bucket(e *Entry, o sortOrder) int {
+	if !o.DirsFirst {
+		return 0
+	}
+	hidden := isHiddenName(e.name)
+	switch {
+	case e.isDir() && hidden:
+		return 0
+	case e.isDir():
+		return 1
+	case hidden:
+		return 3
+	}
+	return 2
+}
+
+func extOf(e *Entry) string {
+	if e.isDir() {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(e.name), "."))
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func lessEntry(a, b *Entry, o sortOrder) bool {
+	if ba, bb := bucket(a, o), bucket(b, o); ba != bb {
+		return ba < bb
+	}
+	c := 0
+	switch o.Mode {
+	case "ext":
+		c = strings.Compare(extOf(a), extOf(b))
+	case "size":
+		c = cmpInt64(a.size, b.size)
+	case "mtime":
+		c = a.modTime.Compare(b.modTime)
+	case "ctime":
+		c = a.ctime.Compare(b.ctime)
+	case "type":
+		c = cmpInt64(int64(a.kind), int64(b.kind))
+		if c == 0 {
+			c = strings.Compare(extOf(a), extOf(b))
+		}
+	}
+	if c == 0 {
+		c = compareEntryNames(a, b)
+	}
+	if o.Desc {
+		c = -c
+	}
+	return c < 0
+}
+
+// compareNames сравнивает имена без учёта регистра, числа внутри имён —
+// по значению, так что file2 идёт раньше file10
+func compareNames(a, b string) int {
+	x, y := a, b
+	for x != "" && y != "" {
+		if isDigit(x[0]) && isDigit(y[0]) {
+			var nx, ny string
+			nx, x = splitDigits(x)
+			ny, y = splitDigits(y)
+			if c := compareNumbers(nx, ny); c != 0 {
+				return c
+			}
+			continue
+		}
+		rx, sx := utf8.DecodeRuneInString(x)
+		ry, sy := utf8.DecodeRuneInString(y)
+		if lx, ly := unicode.ToLower(rx), unicode.ToLower(ry); lx != ly {
+			return int(lx - ly)
+		}
+		x, y = x[sx:], y[sy:]
+	}
+	if c := len(x) - len(y); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareNumbers сравнивает десятичные строки любой длины
+func compareNumbers(a, b string) int {
+	ta, tb := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(ta) != len(tb) {
+		return len(ta) - len(tb)
+	}
+	if c := strings.Compare(ta, tb); c != 0 {
+		return c
+	}
+	// 01 после 1
+	return len(a) - len(b)
+}
+
+func sortEntries(entries []Entry, o sortOrder) {
+	ensureKeys(entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return lessEntry(&entries[i], &entries[j], o)
+	})
+}
+
+// resortPanel применяет новый порядок, не теряя элемент под курсором
+func resortPanel(p *Panel, o sortOrder) {
+	p.sort = o
+	name := ""
+	if e := selectedEntry(p); e != nil {
+		name = e.name
+	}
+	sortEntries(p.all, o)
+	treeResort(p, o)
+	p.items = panelItems(p)
+	selectName(p, name)
+}
+
+// ---------------- sort menu ----------------
+var sortModes = []struct {
+	key  rune
+	mode string
+	text string
+}{
+	{'n', "name", "name"},
+	{'e', "ext", "extension"},
+	{'s', "size", "size"},
+	{'m', "mtime", "modification time"},
+	{'c', "ctime", "change time"},
+	{'t', "type", "type"},
+}
+
+func drawSortMenu(s tcell.Screen, o sortOrder) {
+	lines := []string{"Sort by", ""}
+	for _, m := range sortModes {
+		mark := " "
+		if m.mode == o.Mode {
+			mark = "•"
+		}
+		lines = append(lines, fmt.Sprintf("%s %c  %s", mark, m.key, m.text))
+	}
+	onOff := func(b bool) string {
+		if b {
+			return "on"
+		}
+		return "off"
+	}
+	lines = append(lines, "",
+		fmt.Sprintf("  r  descending [%s]", onOff(o.Desc)),
+		fmt.Sprintf("  d  directories first [%s]", onOff(o.DirsFirst)),
+		fmt.Sprintf("  l  collation [%s]", config.Collation))
+
+	sw, sh := s.Size()
+	w := 34
+	h := len(lines) + 2
+	x := (sw - w) / 2
+	y := (sh - h) / 2
+	drawBox(s, x, y, w, h)
+	textStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	headerStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	for i, line := range lines {
+		style := textStyle
+		if i == 0 {
+			style = headerStyle
+		}
+		drawText(s, x+2, y+1+i, line, style, w-4)
+	}
+}
+
+// sortMenuKey меняет порядок по клавише из меню; false — клавиша не из меню
+func sortMenuKey(o sortOrder, r rune) (sortOrder, bool) {
+	for _, m := range sortModes {
+		if m.key == r {
+			o.Mode = m.mode
+			return o, true
+		}
+	}
+	switch r {
+	case 'r':
+		o.Desc = !o.Desc
+	case 'd':
+		o.DirsFirst = !o.DirsFirst
+	default:
+		return o, false
+	}
+	return o, true
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------- structured previews ----------------
+// JSON, CSV/TSV и Markdown показываются не как есть, а разобранными: JSON —
+// отформатированным деревом со сворачиваемыми узлами, таблицы — колонками
+// по ширине, Markdown — с оформленными заголовками, списками и кодом. Всё
+// это превращается в те же строки с подсветкой, что и обычный текст, так
+// что номера строк, перенос и прокрутка работают без изменений.
+const (
+	jsonMaxBytes  = 4 << 20 // JSON больше этого показываем как текст
+	tableMaxWidth = 30      // ширина колонки таблицы, длиннее — обрезается
+)
+
+// structuredKind выбирает разбор по расширению, а для файлов без знакомого
+// расширения — по содержимому; sniffed сообщает, что вид угадан по содержимому.
+// Угаданный JSON показывается разобранным, только если разбирается без ошибок.
+func structuredKind(path string, head []byte) (kind string, sniffed bool) {
+	base := filepath.Base(path)
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(base), "."))
+	switch ext {
+	case "json", "geojson", "ipynb", "webmanifest":
+		return "json", false
+	case "csv":
+		return "csv", false
+	case "tsv", "tab":
+		return "tsv", false
+	case "md", "markdown", "mdown", "mkd":
+		return "markdown", false
+	case "txt", "text", "log":
+		// объявлен простым текстом
+		return "", false
+	}
+	if _, ok := syntaxByExt[ext]; ok {
+		return "", false
+	}
+	if _, ok := syntaxByName[base]; ok {
+		return "", false
+	}
+
+	text := strings.TrimLeft(string(head), " \t\r\n")
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return "json", true
+	}
+	// таблица — если в первых строках одинаковое число разделителей; запятые
+	// бывают и в обычном тексте, поэтому для них нужно хотя бы три строки
+	lines := strings.Split(text, "\n")
+	if len(lines) > 5 {
+		lines = lines[:5]
+	} else if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, sep := range []string{"\t", ","} {
+		n := strings.Count(lines[0], sep)
+		same := n > 0 && (len(lines) > 2 || sep == "\t" && len(lines) > 1)
+		for _, l := range lines[1:] {
+			same = same && strings.Count(l, sep) == n
+		}
+		if same && sep == "\t" {
+			return "tsv", true
+		} else if same {
+			return "csv", true
+		}
+	}
+	return "", false
+}
+
+// styledLine собирает строку просмотра из кусков с разной подсветкой
+type styledLine struct {
+	text []rune
+	cls  []hlClass
+}
+
+func (l *styledLine) add(s string, c hlClass) {
+	for _, r := range s {
+		l.text = append(l.text, r)
+		l.cls = append(l.cls, c)
+	}
+}
+
+func (d *previewData) addLine(l *styledLine) {
+	d.lines = append(d.lines, string(l.text))
+	d.hl = append(d.hl, l.cls)
+}
+
+// ---------------- JSON ----------------
+type jsonNode struct {
+	key      string // уже в кавычках; "" — элемент массива или корень
+	open     byte   // '{' или '[' у контейнера, 0 у простого значения
+	value    string
+	class    hlClass
+	children []*jsonNode
+	folded   bool
+}
+
+// parseJSON строит дерево, сохраняя порядок ключей
+func parseJSON(data []byte) (*jsonNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var parse func(key string) (*jsonNode, error)
+	parse = func(key string) (*jsonNode, error) {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		n := &jsonNode{key: key}
+		switch t := tok.(type) {
+		case json.Delim:
+			n.open = byte(t)
+			for dec.More() {
+				childKey := ""
+				if n.open == '{' {
+					k, err := dec.Token()
+					if err != nil {
+						return nil, err
+					}
+					q, _ := json.Marshal(k)
+					childKey = string(q)
+				}
+				child, err := parse(childKey)
+				if err != nil {
+					return nil, err
+				}
+				n.children = append(n.children, child)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+		case string:
+			q, _ := json.Marshal(t)
+			n.value, n.class = string(q), hlString
+		case json.Number:
+			n.value, n.class = string(t), hlNumber
+		case bool:
+			n.value, n.class = fmt.Sprint(t), hlKeyword
+		case nil:
+			n.value, n.class = "null", hlKeyword
+		}
+		return n, nil
+	}
+	root, err := parse("")
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = errors.New("unexpected data after the top-level value")
+		}
+	}
+	if err != nil {
+		// место ошибки берём у полной проверки: у Decoder смещение указывает
+		// то на неверный символ, то за него
+		if verr := json.Unmarshal(data, new(json.RawMessage)); verr != nil {
+			err = verr
+		}
+		off := dec.InputOffset()
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			// Offset — сколько байт прочитано вместе с неверным символом
+			off = max(se.Offset-1, 0)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF || strings.HasPrefix(err.Error(), "unexpected end of JSON") {
+			err = errors.New("unexpected end of input")
+			off = int64(len(data))
+		}
+		line := bytes.Count(data[:off], []byte("\n")) + 1
+		col := off - int64(bytes.LastIndexByte(data[:off], '\n'))
+		return nil, &jsonError{msg: err.Error(), line: line, col: int(col)}
+	}
+	return root, nil
+}
+
+type jsonError struct {
+	msg       string
+	line, col int
+}
+
+func (e *jsonError) Error() string {
+	return fmt.Sprintf("invalid JSON: %s (line %d, column %d)", e.msg, e.line, e.col)
+}
+
+// previewJSON форматирует JSON; false — показать как текст (в banner
+// причина)
+func previewJSON(d *previewData, f *os.File, head []byte, truncated bool) bool {
+	data := head
+	if truncated {
+		rest, err := io.ReadAll(io.LimitReader(f, jsonMaxBytes-int64(len(head))+1))
+		if err != nil {
+			return false
+		}
+		data = append(head, rest...)
+		if len(data) > jsonMaxBytes {
+			d.banner = "(too large to format, showing the beginning as text)"
+			return false
+		}
+	}
+	root, err := parseJSON(data)
+	if err != nil {
+		d.banner = err.Error()
+		var je *jsonError
+		if errors.As(err, &je) {
+			d.errLine = je.line
+		}
+		return false
+	}
+	d.json = root
+	d.lang = "json"
+	d.layoutJSON()
+	return true
+}
+
+// layoutJSON заново раскладывает дерево в строки, например после
+// сворачивания узла; nodes[i] — узел, к которому относится строка i
+func (d *previewData) layoutJSON() {
+	d.lines, d.hl, d.nodes = nil, nil, nil
+	var walk func(n *jsonNode, depth int, last bool)
+	walk = func(n *jsonNode, depth int, last bool) {
+		comma := ","
+		if last {
+			comma = ""
+		}
+		l := &styledLine{}
+		l.add(strings.Repeat("  ", depth), hlText)
+		if n.key != "" {
+			l.add(n.key, hlKey)
+			l.add(": ", hlText)
+		}
+		closer := "}"
+		if n.open == '[' {
+			closer = "]"
+		}
+		switch {
+		case n.open == 0:
+			l.add(n.value, n.class)
+			l.add(comma, hlText)
+		case len(n.children) == 0:
+			l.add(string(n.open)+closer+comma, hlText)
+		case n.folded:
+			l.add(string(n.open)+"…"+closer+comma, hlText)
+			what := "items"
+			if n.open == '{' {
+				what = "keys"
+			}
+			l.add(fmt.Sprintf("  %d %s", len(n.children), what), hlComment)
+		default:
+			l.add(string(n.open), hlText)
+			d.addLine(l)
+			d.nodes = append(d.nodes, n)
+			for i, c := range n.children {
+				walk(c, depth+1, i == len(n.children)-1)
+			}
+			l = &styledLine{}
+			l.add(strings.Repeat("  ", depth)+closer+comma, hlText)
+		}
+		d.addLine(l)
+		d.nodes = append(d.nodes, n)
+	}
+	walk(d.json, 0, true)
+}
+
+// toggleFold сворачивает или разворачивает узел под курсором просмотра
+func (d *previewData) toggleFold() {
+	if d.json == nil || d.cursor >= len(d.nodes) {
+		return
+	}
+	n := d.nodes[d.cursor]
+	if n.open == 0 || len(n.children) == 0 {
+		return
+	}
+	n.folded = !n.folded
+	d.layoutJSON()
+	// курсор — на первую строку узла, закрывающая скобка могла исчезнуть
+	for i, m := range d.nodes {
+		if m == n {
+			d.cursor = i
+			break
+		}
+	}
+}
+
+// foldAll сворачивает все узлы ниже корня, а если всё уже свёрнуто —
+// разворачивает
+func (d *previewData) foldAll() {
+	if d.json == nil {
+		return
+	}
+	fold := false
+	for _, c := range d.json.children {
+		if c.open != 0 && len(c.children) > 0 && !c.folded {
+			fold = true
+		}
+	}
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		for _, c := range n.children {
+			c.folded = fold && c.open != 0
+			walk(c)
+		}
+	}
+	walk(d.json)
+	d.layoutJSON()
+	d.cursor = 0
+}
+
+// ---------------- CSV/TSV ----------------
+// previewTable выравнивает записи по колонкам; первая строка — заголовок
+func previewTable(d *previewData, text, kind string, truncated bool) bool {
+	r := csv.NewReader(strings.NewReader(text))
+	if kind == "tsv" {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		d.banner = fmt.Sprintf("invalid %s: %v", strings.ToUpper(kind), err)
+		return false
+	}
+	if truncated && len(records) > 1 {
+		// последняя запись могла оборваться на середине
+		records = records[:len(records)-1]
+	}
+	if len(records) == 0 {
+		return false
+	}
+	var widths []int
+	for _, rec := range records {
+		for i, field := range rec {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], min(len([]rune(field)), tableMaxWidth))
+		}
+	}
+	row := func(rec []string, c hlClass) {
+		l := &styledLine{}
+		for i, w := range widths {
+			if i > 0 {
+				l.add(" │ ", hlComment)
+			}
+			field := ""
+			if i < len(rec) {
+				field = strings.ReplaceAll(rec[i], "\n", "↵")
+			}
+			rs := []rune(field)
+			if len(rs) > w {
+				rs = append(rs[:w-1], '…')
+			}
+			l.add(string(rs)+strings.Repeat(" ", w-len(rs)), c)
+		}
+		d.addLine(l)
+	}
+	row(records[0], hlHeading)
+	sep := &styledLine{}
+	for i, w := range widths {
+		if i > 0 {
+			sep.add("─┼─", hlComment)
+		}
+		sep.add(strings.Repeat("─", w), hlComment)
+	}
+	d.addLine(sep)
+	for _, rec := range records[1:] {
+		row(rec, hlText)
+	}
+	d.lang = fmt.Sprintf("%s %d×%d", kind, len(records)-1, len(widths))
+	return true
+}
+
+// ---------------- Markdown ----------------
+// previewMarkdown оформляет строки d.lines: заголовки, списки, цитаты,
+// линии и блоки кода с подсветкой языка из ```lang
+func previewMarkdown(d *previewData) {
+	src := d.lines
+	d.lines, d.hl = nil, nil
+	var fence []string
+	var fenceSyn *syntax
+	inFence := false
+	for _, line := range src {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if !inFence {
+				inFence = true
+				lang := strings.ToLower(strings.TrimSpace(trimmed[3:]))
+				fenceSyn = syntaxNamed(lang)
+				if name, ok := syntaxByExt[lang]; ok {
+					fenceSyn = syntaxNamed(name)
+				}
+				fence = nil
+				continue
+			}
+			inFence = false
+			var hl [][]hlClass
+			if fenceSyn != nil {
+				hl = highlightLines(fence, fenceSyn)
+			}
+			for i, code := range fence {
+				l := &styledLine{}
+				l.add("│ ", hlComment)
+				if hl != nil {
+					l.text = append(l.text, []rune(code)...)
+					l.cls = append(l.cls, hl[i]...)
+				} else {
+					l.add(code, hlString)
+				}
+				d.addLine(l)
+			}
+			fence = nil
+			continue
+		}
+		if inFence {
+			fence = append(fence, line)
+			continue
+		}
+
+		l := &styledLine{}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			title := strings.TrimSpace(strings.TrimRight(trimmed[level:], "#"))
+			if level > 6 || (len(trimmed) > level && trimmed[level] != ' ') {
+				markdownInline(l, line, hlText)
+				break
+			}
+			markdownInline(l, title, hlHeading)
+			if level <= 2 {
+				d.addLine(l)
+				under := "═"
+				if level == 2 {
+					under = "─"
+				}
+				l = &styledLine{}
+				l.add(strings.Repeat(under, len([]rune(title))), hlHeading)
+			}
+		case isRule(trimmed):
+			l.add(strings.Repeat("─", 40), hlComment)
+		case strings.HasPrefix(trimmed, ">"):
+			l.add(indent+"│ ", hlComment)
+			markdownInline(l, strings.TrimSpace(trimmed[1:]), hlEmph)
+		case strings.HasPrefix(trimmed, "- [ ] ") || strings.HasPrefix(trimmed, "* [ ] "):
+			l.add(indent+"☐ ", hlKeyword)
+			markdownInline(l, trimmed[6:], hlText)
+		case strings.HasPrefix(trimmed, "- [x] ") || strings.HasPrefix(trimmed, "* [x] ") || strings.HasPrefix(trimmed, "- [X] "):
+			l.add(indent+"☑ ", hlKeyword)
+			markdownInline(l, trimmed[6:], hlText)
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") || strings.HasPrefix(trimmed, "+ "):
+			l.add(indent+"• ", hlKeyword)
+			markdownInline(l, trimmed[2:], hlText)
+		case orderedItem(trimmed) > 0:
+			n := orderedItem(trimmed)
+			l.add(indent+trimmed[:n], hlKeyword)
+			markdownInline(l, trimmed[n:], hlText)
+		default:
+			markdownInline(l, line, hlText)
+		}
+		d.addLine(l)
+	}
+	// незакрытый блок кода показываем как есть
+	for _, code := range fence {
+		l := &styledLine{}
+		l.add("│ ", hlComment)
+		l.add(code, hlString)
+		d.addLine(l)
+	}
+	d.lang = "markdown"
+}
+
+// isRule распознаёт горизонтальную линию: ---, *** или ___
+func isRule(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	for _, c := range "-*_" {
+		if strings.Trim(s, string(c)) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// orderedItem возвращает длину "12. " в начале пункта нумерованного списка
+func orderedItem(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i+1 >= len(s) || (s[i] != '.' && s[i] != ')') || s[i+1] != ' ' {
+		return 0
+	}
+	return i + 2
+}
+
+// markdownInline добавляет текст с оформлением `кода`, **жирного**,
+// *курсива* и [ссылок](адрес) — от ссылки остаётся только текст
+func markdownInline(l *styledLine, s string, base hlClass) {
+	r := []rune(s)
+	closing := func(from int, marker string) int {
+		for k := from; k < len(r); k++ {
+			if hasPrefixAt(r, k, marker) {
+				return k
+			}
+		}
+		return -1
+	}
+	for i := 0; i < len(r); {
+		switch {
+		case r[i] == '`':
+			if end := closing(i+1, "`"); end > i {
+				l.add(string(r[i+1:end]), hlString)
+				i = end + 1
+				continue
+			}
+		case hasPrefixAt(r, i, "**") || hasPrefixAt(r, i, "__"):
+			if end := closing(i+2, string(r[i:i+2])); end > i+2 {
+				markdownInline(l, string(r[i+2:end]), hlBold)
+				i = end + 2
+				continue
+			}
+		case r[i] == '*' && i+1 < len(r) && r[i+1] != ' ':
+			if end := closing(i+1, "*"); end > i+1 {
+				markdownInline(l, string(r[i+1:end]), hlEmph)
+				i = end + 1
+				continue
+			}
+		case r[i] == '[' || (r[i] == '!' && i+1 < len(r) && r[i+1] == '['):
+			start := i + 1
+			if r[i] == '!' {
+				start++
+			}
+			if mid := closing(start, "]("); mid >= start {
+				if end := closing(mid+2, ")"); end > mid {
+					text := string(r[start:mid])
+					if r[i] == '!' {
+						text = "[image: " + text + "]"
+					}
+					l.add(text, hlLink)
+					i = end + 1
+					continue
+				}
+			}
+		}
+		l.add(string(r[i]), base)
+		i++
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStructuredKind(t *testing.T) {
+	tests := []struct {
+		path, head string
+		kind       string
+		sniffed    bool
+	}{
+		{"a.json", "not json at all", "json", false},
+		{"A.JSON", "{}", "json", false},
+		{"x.ipynb", "{}", "json", false},
+		{"t.csv", "a", "csv", false},
+		{"t.tsv", "a", "tsv", false},
+		{"README.md", "text", "markdown", false},
+		{"main.go", "{", "", false},
+		{"Makefile", "a,b\nc,d\ne,f\n", "", false},
+		{"notes.txt", "a,b\nc,d\n", "", false},
+		{"notes.txt", "{\"a\": 1}", "", false},
+		{"app.log", "x\ty\nz\tw\n", "", false},
+		// без знакомого расширения — по содержимому
+		{"data", "  \n{\"a\": 1}", "json", true},
+		{"data", "[1, 2]", "json", true},
+		{"data", "# Title\n\ntext", "", false},
+		{"data", "a\tb\nc\td\n", "tsv", true},
+		{"data", "a\tb\nc\td", "tsv", true},
+		{"data", "a\tb\n", "", false},
+		{"data", "a\tb\nc d\n", "", false},
+		{"data", "a,b\nc,d\ne,f\n", "csv", true},
+		{"data", "a,b\nc,d\n", "", false},
+		{"data", "Hello, world.\nBye, then.\nYes, no, maybe.\n", "", false},
+		{"data", "plain text", "", false},
+		{"data", "", "", false},
+		{"dump.dat", "1,2,3\n4,5,6\n7,8,9\n10,11,12\n13,14,15\n16,17\n", "csv", true},
+	}
+	for _, tt := range tests {
+		kind, sniffed := structuredKind(tt.path, []byte(tt.head))
+		if kind != tt.kind || sniffed != tt.sniffed {
+			t.Errorf("structuredKind(%q, %q) = %q, %v; want %q, %v", tt.path, tt.head, kind, sniffed, tt.kind, tt.sniffed)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		in    string
+		lines []string // раскладка layoutJSON
+	}{
+		{`{"b": 1, "a": [true, null, "x"], "c": {}}`, []string{
+			`{`,
+			`  "b": 1,`,
+			`  "a": [`,
+			`    true,`,
+			`    null,`,
+			`    "x"`,
+			`  ],`,
+			`  "c": {}`,
+			`}`,
+		}},
+		{`[]`, []string{`[]`}},
+		{` 1.50e3 `, []string{`1.50e3`}},
+		{`"aé\"b"`, []string{`"aé\"b"`}},
+		{`{"k\n": [[1]]}`, []string{`{`, `  "k\n": [`, `    [`, `      1`, `    ]`, `  ]`, `}`}},
+	}
+	for _, tt := range tests {
+		root, err := parseJSON([]byte(tt.in))
+		if err != nil {
+			t.Errorf("parseJSON(%q) error: %v", tt.in, err)
+			continue
+		}
+		d := &previewData{json: root}
+		d.layoutJSON()
+		if strings.Join(d.lines, "\n") != strings.Join(tt.lines, "\n") {
+			t.Errorf("parseJSON(%q) layout:\n%s\nwant:\n%s", tt.in, strings.Join(d.lines, "\n"), strings.Join(tt.lines, "\n"))
+		}
+		if len(d.nodes) != len(d.lines) || len(d.hl) != len(d.lines) {
+			t.Errorf("parseJSON(%q): %d lines, %d nodes, %d highlights", tt.in, len(d.lines), len(d.nodes), len(d.hl))
+		}
+	}
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	tests := []struct {
+		in        string
+		line, col int
+	}{
+		{`{"a": 1,}`, 1, 9},
+		{"{\n  \"a\": 1\n  \"b\": 2\n}", 3, 3},
+		{`[1, 2`, 1, 6},
+		{``, 1, 1},
+		{`{"a": 1} {"b": 2}`, 1, 10},
+		{"[\n\ttrue,\n\tnope\n]", 3, 3},
+	}
+	for _, tt := range tests {
+		_, err := parseJSON([]byte(tt.in))
+		var je *jsonError
+		if !errors.As(err, &je) {
+			t.Errorf("parseJSON(%q) error = %v, want *jsonError", tt.in, err)
+			continue
+		}
+		if je.line != tt.line || je.col != tt.col {
+			t.Errorf("parseJSON(%q) error at %d:%d (%s), want %d:%d", tt.in, je.line, je.col, je.msg, tt.line, tt.col)
+		}
+	}
+}
+
+func TestOrderedItem(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"1. item", 3},
+		{"12. item", 4},
+		{"3) item", 3},
+		{"1.item", 0},
+		{"1.", 0},
+		{"1. ", 3},
+		{"a. item", 0},
+		{". item", 0},
+		{"- item", 0},
+		{"", 0},
+		{"2024 was", 0},
+	}
+	for _, tt := range tests {
+		if got := orderedItem(tt.in); got != tt.want {
+			t.Errorf("orderedItem(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
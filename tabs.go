@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- tabs ----------------
+// Вкладка — отдельная панель списка файлов со своим путём, курсором,
+// историей, сортировкой и фильтром; активная вкладка и есть filelist.
+// В раскладке dual у вкладки есть ещё левая панель (Panel.left).
+type tabState struct {
+	Path string `json:"path"`
+	Name string `json:"name"`           // элемент под курсором
+	Left string `json:"left,omitempty"` // каталог левой панели в раскладке dual
+}
+
+type tabsState struct {
+	Active int        `json:"active"`
+	Tabs   []tabState `json:"tabs"`
+	Left   string     `json:"left,omitempty"` // прежний формат: одна левая панель на все вкладки
+}
+
+func tabsFile() string {
+	return filepath.Join(homeDir, ".myfm_tabs.json")
+}
+
+// saveTabs запоминает набор вкладок; удалённые адреса не сохраняем — в них
+// может быть пароль, а недоступный сервер задержал бы запуск
+func saveTabs(tabs []*Panel, active int) {
+	st := tabsState{}
+	for i, t := range tabs {
+		if isRemote(t.path) || t.path == "" {
+			continue
+		}
+		if i == active {
+			st.Active = len(st.Tabs)
+		}
+		name := ""
+		if e := selectedEntry(t); e != nil {
+			name = e.name
+		}
+		left := ""
+		if t.left != nil && !isRemote(t.left.path) {
+			left = t.left.path
+		}
+		st.Tabs = append(st.Tabs, tabState{Path: t.path, Name: name, Left: left})
+	}
+	data, _ := json.MarshalIndent(st, "", "  ")
+	_ = os.WriteFile(tabsFile(), data, 0644)
+}
+
+func loadTabs() tabsState {
+	var st tabsState
+	data, err := os.ReadFile(tabsFile())
+	if err != nil {
+		return st
+	}
+	_ = json.Unmarshal(data, &st)
+	if st.Active < 0 || st.Active >= len(st.Tabs) {
+		st.Active = 0
+	}
+	return st
+}
+
+// newTab создаёт панель вкладки с геометрией и видом proto
+func newTab(proto *Panel, path string) *Panel {
+	return &Panel{
+		x: proto.x, y: proto.y, w: proto.w, h: proto.h,
+		border:   true,
+		path:     path,
+		detailed: proto.detailed,
+		sort:     sortFor(path),
+	}
+}
+
+func tabTitle(p *Panel) string {
+	if isRootPath(p.path) {
+		return displayPath(p.path)
+	}
+	return baseName(p.path)
+}
+
+// drawTabBar рисует вкладки в строку; если все не влезают, сдвигает полосу
+// так, чтобы активная была видна
+func drawTabBar(s tcell.Screen, x, y, w int, tabs []*Panel, active int) {
+	labels := make([]string, len(tabs))
+	for i, t := range tabs {
+		title := []rune(tabTitle(t))
+		if len(title) > 20 {
+			title = append(title[:19], '…')
+		}
+		labels[i] = fmt.Sprintf(" %d:%s ", i+1, string(title))
+	}
+	first := 0
+	for {
+		width := 0
+		for i := first; i <= active; i++ {
+			width += len([]rune(labels[i])) + 1
+		}
+		if width <= w || first == active {
+			break
+		}
+		first++
+	}
+
+	style := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	activeStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow).Reverse(true)
+	col := 0
+	for i := first; i < len(tabs) && col < w; i++ {
+		st := style
+		if i == active {
+			st = activeStyle
+		}
+		n := len([]rune(labels[i]))
+		drawText(s, x+col, y, labels[i], st, min(n, w-col))
+		col += n + 1
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ---------------- tree view ----------------
+// В режиме дерева подкаталоги раскрываются прямо в списке. Имена вложенных
+// записей — пути от каталога панели, поэтому entryPath и все файловые
+// операции работают с выбранным узлом без изменений. Дети читаются в фоне
+// при раскрытии и перечитываются вместе с самим каталогом.
+const treeMaxItems = 50000 // "раскрыть всё" останавливается на таком размере дерева
+
+type treeState struct {
+	root     string
+	gen      int                // loadGen панели, для которой прочитаны дети
+	expanded map[string]bool    // раскрытые каталоги
+	children map[string][]Entry // прочитанные дети по пути каталога
+	loading  map[string]bool
+	depth    int    // "раскрыть всё": раскрывать прочитанных детей до этой глубины
+	want     string // после перечитывания вернуть курсор на этот узел
+}
+
+// treeLoadEvent несёт прочитанное содержимое раскрытого каталога
+type treeLoadEvent struct {
+	when    time.Time
+	panel   *Panel
+	gen     int
+	rel     string
+	entries []Entry
+	err     error
+}
+
+func (e *treeLoadEvent) When() time.Time { return e.when }
+
+func newTreeState(p *Panel) *treeState {
+	return &treeState{
+		root:     p.path,
+		gen:      p.loadGen,
+		expanded: map[string]bool{},
+		children: map[string][]Entry{},
+		loading:  map[string]bool{},
+	}
+}
+
+// toggleTree включает и выключает дерево; при выключении курсор встаёт на
+// верхний каталог выбранного узла
+func toggleTree(p *Panel) {
+	if p.tree == nil {
+		p.tree = newTreeState(p)
+		refilter(p)
+		return
+	}
+	name := ""
+	if e := selectedEntry(p); e != nil {
+		name = strings.SplitN(e.name, string(filepath.Separator), 2)[0]
+	}
+	p.tree = nil
+	refilter(p)
+	selectName(p, name)
+}
+
+// treeDepth — уровень узла: у записей самого каталога 1
+func treeDepth(rel string) int {
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// panelItems строит видимый список панели: отфильтрованные записи, а в режиме
+// дерева — ещё и дети раскрытых каталогов с направляющими
+func panelItems(p *Panel) []Entry {
+	items := filterEntries(p.all, p.filter)
+	t := p.tree
+	if t == nil {
+		return items
+	}
+	// пока читается другой каталог, раскрытия старого к нему не применяем
+	open := func(e *Entry) bool {
+		return e.isDir() && t.root == p.path && t.expanded[e.name]
+	}
+	var res []Entry
+	var walk func(list []Entry, guide string, nested bool)
+	walk = func(list []Entry, guide string, nested bool) {
+		for i, e := range list {
+			conn, next := "", guide
+			if nested {
+				conn, next = "├─ ", guide+"│  "
+				if i == len(list)-1 {
+					conn, next = "└─ ", guide+"   "
+				}
+			}
+			mark := "  "
+			if e.isDir() {
+				mark = "▸ "
+				if open(&e) {
+					mark = "▾ "
+				}
+			}
+			e.tree = guide + conn + mark
+			res = append(res, e)
+			if open(&e) {
+				walk(t.children[e.name], next, true)
+			}
+		}
+	}
+	walk(items, "", false)
+	return res
+}
+
+// treeRefresh после (пере)чтения каталога панели перечитывает раскрытые
+// подкаталоги; при смене каталога дерево сворачивается
+func treeRefresh(p *Panel, out chan<- tcell.Event) {
+	t := p.tree
+	if t == nil || p.loading != nil || t.gen == p.loadGen {
+		return
+	}
+	if t.root != p.path {
+		*t = *newTreeState(p)
+		refilter(p)
+		return
+	}
+	// старые дети видны, пока не придут новые
+	t.gen = p.loadGen
+	t.loading = map[string]bool{}
+	for rel := range t.expanded {
+		treeLoad(p, rel, out)
+	}
+}
+
+func treeLoad(p *Panel, rel string, out chan<- tcell.Event) {
+	t := p.tree
+	t.loading[rel] = true
+	dir := joinPath(p.path, rel)
+	gen := t.gen
+	go func() {
+		ev := &treeLoadEvent{when: time.Now(), panel: p, gen: gen, rel: rel}
+		var des []os.DirEntry
+		if isRemote(dir) {
+			des, ev.err = davReadDir(davCtx, dir)
+		} else {
+			des, ev.err = os.ReadDir(dir)
+		}
+		for _, de := range des {
+			ev.entries = append(ev.entries, newEntry(dir, de))
+		}
+		out <- ev
+	}()
+}
+
+func applyTreeLoad(ev *treeLoadEvent, out chan<- tcell.Event) {
+	p := ev.panel
+	t := p.tree
+	if t == nil || ev.gen != t.gen || !t.loading[ev.rel] {
+		return
+	}
+	delete(t.loading, ev.rel)
+	if ev.err != nil {
+		delete(t.expanded, ev.rel)
+	} else {
+		// скрытые отбрасываем и сортируем по коротким именам, потом
+		// переименовываем в пути от каталога панели
+		entries := arrangeEntries(ev.entries, p.sort)
+		for i := range entries {
+			entries[i].name = filepath.Join(ev.rel, entries[i].name)
+		}
+		t.children[ev.rel] = entries
+		if t.depth > 0 && len(p.items) < treeMaxItems {
+			for i := range entries {
+				e := &entries[i]
+				if e.isDir() && e.mode&os.ModeSymlink == 0 && treeDepth(e.name) < t.depth {
+					t.expanded[e.name] = true
+					treeLoad(p, e.name, out)
+				}
+			}
+		}
+	}
+	refilter(p)
+	if t.want != "" && selectName(p, t.want) {
+		t.want = ""
+	}
+}
+
+// treeExpand раскрывает каталог под курсором; уже раскрытый — переводит
+// курсор на первого ребёнка
+func treeExpand(p *Panel, out chan<- tcell.Event) {
+	e := selectedEntry(p)
+	if e == nil || !e.isDir() {
+		return
+	}
+	t := p.tree
+	if t.expanded[e.name] {
+		if _, ok := t.children[e.name]; ok && p.cursor+1 < len(p.items) && treeDepth(p.items[p.cursor+1].name) > treeDepth(e.name) {
+			p.cursor++
+			ensureCursorBounds(p)
+		}
+		return
+	}
+	t.expanded[e.name] = true
+	treeLoad(p, e.name, out)
+	refilter(p)
+}
+
+// treeCollapse сворачивает раскрытый каталог под курсором или переходит к
+// родительскому узлу; false — курсор уже на верхнем уровне
+func treeCollapse(p *Panel) bool {
+	e := selectedEntry(p)
+	if e == nil {
+		return false
+	}
+	t := p.tree
+	t.depth = 0
+	if e.isDir() && t.expanded[e.name] {
+		// вложенные раскрытия забываем вместе с ним
+		prefix := e.name + string(filepath.Separator)
+		for rel := range t.expanded {
+			if rel == e.name || strings.HasPrefix(rel, prefix) {
+				delete(t.expanded, rel)
+			}
+		}
+		refilter(p)
+		return true
+	}
+	if treeDepth(e.name) == 1 {
+		return false
+	}
+	selectName(p, filepath.Dir(e.name))
+	return true
+}
+
+// treeExpandAll раскрывает дерево до depth уровней; 1 — свернуть всё
+func treeExpandAll(p *Panel, depth int, out chan<- tcell.Event) {
+	t := p.tree
+	t.depth = depth
+	t.expanded = map[string]bool{}
+	var expand func(list []Entry)
+	expand = func(list []Entry) {
+		for i := range list {
+			e := &list[i]
+			if !e.isDir() || e.mode&os.ModeSymlink != 0 || treeDepth(e.name) >= depth {
+				continue
+			}
+			t.expanded[e.name] = true
+			if children, ok := t.children[e.name]; ok {
+				expand(children)
+			} else if !t.loading[e.name] {
+				treeLoad(p, e.name, out)
+			}
+		}
+	}
+	expand(filterEntries(p.all, p.filter))
+	refilter(p)
+}
+
+// treeResort применяет новый порядок к прочитанным детям
+func treeResort(p *Panel, o sortOrder) {
+	if p.tree == nil {
+		return
+	}
+	for _, list := range p.tree.children {
+		sortEntries(list, o)
+	}
+}
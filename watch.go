@@ -0,0 +1,20 @@
+package main
+
+import "time"
+
+// fsChangeEvent приходит в общий канал событий, когда отслеживаемые пути изменились
+type fsChangeEvent struct {
+	when  time.Time
+	paths []string
+}
+
+func (e *fsChangeEvent) When() time.Time { return e.when }
+
+func (e *fsChangeEvent) has(p string) bool {
+	for _, c := range e.paths {
+		if c == p {
+			return true
+		}
+	}
+	return false
+}
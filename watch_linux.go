@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// задержка, за которую накопившиеся изменения сливаются в одно событие
+const watchCoalesce = 200 * time.Millisecond
+
+// У каталога запись в любой его файл (IN_MODIFY) не отслеживаем: растущий лог
+// перечитывал бы список без конца; размер обновится по IN_CLOSE_WRITE. Файлу
+// под курсором IN_MODIFY нужен, чтобы просмотр шёл за изменениями.
+const (
+	dirWatchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+	fileWatchMask = dirWatchMask | syscall.IN_MODIFY
+)
+
+// dirWatcher следит через inotify за каталогом списка и целью просмотра
+type dirWatcher struct {
+	fd   int
+	file *os.File
+	out  chan<- tcell.Event
+
+	mu    sync.Mutex
+	wds   map[int]string
+	paths map[string]int
+
+	changed chan string
+}
+
+func newDirWatcher(out chan<- tcell.Event) (*dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &dirWatcher{
+		fd: fd,
+		// неблокирующий дескриптор попадает в поллер рантайма, поэтому Close прерывает Read
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		out:     out,
+		wds:     map[int]string{},
+		paths:   map[string]int{},
+		changed: make(chan string, 64),
+	}
+	go w.readLoop()
+	go w.coalesceLoop()
+	return w, nil
+}
+
+// watch заменяет набор отслеживаемых путей; удалённые (WebDAV) пути пропускаются
+func (w *dirWatcher) watch(paths ...string) {
+	want := map[string]bool{}
+	for _, p := range paths {
+		if p != "" && !isRemote(p) {
+			want[p] = true
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Fd() перевёл бы дескриптор в блокирующий режим, поэтому храним его отдельно
+	fd := w.fd
+	for p, wd := range w.paths {
+		if !want[p] {
+			syscall.InotifyRmWatch(fd, uint32(wd))
+			delete(w.paths, p)
+			delete(w.wds, wd)
+		}
+	}
+	for p := range want {
+		if _, ok := w.paths[p]; ok {
+			continue
+		}
+		mask := uint32(fileWatchMask)
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			mask = dirWatchMask
+		}
+		wd, err := syscall.InotifyAddWatch(fd, p, mask)
+		if err != nil {
+			continue
+		}
+		w.paths[p] = wd
+		w.wds[wd] = p
+	}
+}
+
+func (w *dirWatcher) close() {
+	w.file.Close()
+}
+
+func (w *dirWatcher) readLoop() {
+	defer close(w.changed)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			w.mu.Lock()
+			p, ok := w.wds[int(ev.Wd)]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				// ядро само сняло наблюдение (каталог удалён) — забываем его
+				delete(w.wds, int(ev.Wd))
+				if ok && w.paths[p] == int(ev.Wd) {
+					delete(w.paths, p)
+				}
+			}
+			w.mu.Unlock()
+			if ok {
+				w.changed <- p
+			}
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+		}
+	}
+}
+
+// coalesceLoop копит изменения в течение watchCoalesce и отправляет их одним событием
+func (w *dirWatcher) coalesceLoop() {
+	pending := map[string]bool{}
+	var flush <-chan time.Time
+	for {
+		select {
+		case p, ok := <-w.changed:
+			if !ok {
+				return
+			}
+			pending[p] = true
+			if flush == nil {
+				flush = time.After(watchCoalesce)
+			}
+		case <-flush:
+			ev := &fsChangeEvent{when: time.Now()}
+			for p := range pending {
+				ev.paths = append(ev.paths, p)
+			}
+			pending = map[string]bool{}
+			flush = nil
+			w.out <- ev
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import "github.com/gdamore/tcell/v2"
+
+// dirWatcher без inotify ничего не отслеживает: список обновляется только по 'r'
+type dirWatcher struct{}
+
+func newDirWatcher(out chan<- tcell.Event) (*dirWatcher, error) {
+	return &dirWatcher{}, nil
+}
+
+func (w *dirWatcher) watch(paths ...string) {}
+
+func (w *dirWatcher) close() {}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------- paths ----------------
+// Пути вида http(s)://host/dir открываются через WebDAV, остальные — локально.
+func isRemote(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// splitRemote делит адрес на "схема://хост" и путь на сервере
+func splitRemote(p string) (base, rel string) {
+	i := strings.Index(p, "://") + 3
+	j := strings.IndexByte(p[i:], '/')
+	if j < 0 {
+		return p, "/"
+	}
+	return p[:i+j], p[i+j:]
+}
+
+func joinPath(dir, name string) string {
+	if isRemote(dir) {
+		base, rel := splitRemote(dir)
+		return base + path.Join(rel, name)
+	}
+	return filepath.Join(dir, name)
+}
+
+func parentPath(p string) string {
+	if isRemote(p) {
+		base, rel := splitRemote(p)
+		return base + path.Dir(rel)
+	}
+	return filepath.Dir(p)
+}
+
+func baseName(p string) string {
+	if isRemote(p) {
+		_, rel := splitRemote(p)
+		return path.Base(rel)
+	}
+	return filepath.Base(p)
+}
+
+func normalizePath(p string) string {
+	if isRemote(p) {
+		base, rel := splitRemote(p)
+		return base + path.Clean(rel)
+	}
+	return filepath.Clean(p)
+}
+
+// isRootPath сообщает, что выше подниматься некуда
+func isRootPath(p string) bool {
+	return parentPath(p) == p
+}
+
+// displayPath скрывает пароль, если он указан в адресе
+func displayPath(p string) string {
+	if !isRemote(p) {
+		return p
+	}
+	base, rel := splitRemote(p)
+	u, err := url.Parse(base)
+	if err != nil {
+		return p
+	}
+	return u.Redacted() + rel
+}
+
+// Операции с файлами идут в фоне (см. fileop.go) и прерываются отменой ctx.
+func statPath(ctx context.Context, p string) (os.FileInfo, error) {
+	if isRemote(p) {
+		return davStat(ctx, p)
+	}
+	return os.Stat(p)
+}
+
+func removePath(ctx context.Context, p string) error {
+	if isRemote(p) {
+		return davDelete(ctx, p)
+	}
+	return os.RemoveAll(p)
+}
+
+func renamePath(ctx context.Context, src, dst string) error {
+	switch {
+	case isRemote(src) && isRemote(dst):
+		return davMove(ctx, src, dst)
+	case !isRemote(src) && !isRemote(dst):
+		return os.Rename(src, dst)
+	}
+	// между сервером и локальным диском — копируем и удаляем источник
+	if err := copyPath(ctx, src, dst); err != nil {
+		return err
+	}
+	return removePath(ctx, src)
+}
+
+func copyPath(ctx context.Context, src, dst string) error {
+	switch {
+	case isRemote(src) && isRemote(dst):
+		return davCopy(ctx, src, dst)
+	case isRemote(src):
+		return davDownload(ctx, src, dst)
+	case isRemote(dst):
+		return davUpload(ctx, src, dst)
+	}
+	return copyRecursive(ctx, src, dst)
+}
+
+// ---------------- webdav client ----------------
+var (
+	// общего таймаута у клиента нет: он оборвал бы загрузку большого файла.
+	// Зависшее соединение ловят таймауты транспорта, копирование прерывается
+	// своим контекстом, а при выходе все запросы — отменой davCtx.
+	davClient = &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	}}
+	davCtx, davCancel = context.WithCancel(context.Background())
+
+	// сведения о файлах из последних PROPFIND, чтобы отрисовка не ходила в сеть
+	davCacheMu sync.Mutex
+	davCache   = map[string]os.FileInfo{}
+)
+
+type davFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *davFileInfo) Name() string       { return fi.name }
+func (fi *davFileInfo) Size() int64        { return fi.size }
+func (fi *davFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *davFileInfo) IsDir() bool        { return fi.dir }
+func (fi *davFileInfo) Sys() any           { return nil }
+
+func (fi *davFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href     string `xml:"DAV: href"`
+	Propstat []struct {
+		Status string `xml:"DAV: status"`
+		Prop   struct {
+			ResourceType struct {
+				Collection *struct{} `xml:"DAV: collection"`
+			} `xml:"DAV: resourcetype"`
+			ContentLength string `xml:"DAV: getcontentlength"`
+			LastModified  string `xml:"DAV: getlastmodified"`
+		} `xml:"DAV: prop"`
+	} `xml:"DAV: propstat"`
+}
+
+const davPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+// davURL строит адрес запроса; данные для входа возвращаются отдельно
+func davURL(p string) (*url.URL, *url.Userinfo, error) {
+	base, rel := splitRemote(p)
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := u.User
+	u.User = nil
+	u.Path = rel
+	return u, user, nil
+}
+
+func davDo(ctx context.Context, method, p string, body io.Reader, header map[string]string) (*http.Response, error) {
+	u, user, err := davURL(p)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		pass, _ := user.Password()
+		req.SetBasicAuth(user.Username(), pass)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	// длину тела клиент берёт из поля запроса, а не из заголовка
+	if cl := req.Header.Get("Content-Length"); cl != "" {
+		req.ContentLength, _ = strconv.ParseInt(cl, 10, 64)
+		req.Header.Del("Content-Length")
+	}
+	resp, err := davClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s", method, u.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+func davPropfind(ctx context.Context, p, depth string) (*davMultistatus, error) {
+	resp, err := davDo(ctx, "PROPFIND", p, strings.NewReader(davPropfindBody), map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+// davInfo разбирает ответ PROPFIND; href может быть как путём, так и полным адресом
+func davInfo(r davResponse) (string, *davFileInfo) {
+	href := r.Href
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	href = strings.TrimSuffix(href, "/")
+	if href == "" {
+		href = "/"
+	}
+	fi := &davFileInfo{name: path.Base(href)}
+	for _, ps := range r.Propstat {
+		if !strings.Contains(ps.Status, " 200") {
+			continue
+		}
+		if ps.Prop.ResourceType.Collection != nil {
+			fi.dir = true
+		}
+		if n, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err == nil {
+			fi.size = n
+		}
+		if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+			fi.modTime = t
+		}
+	}
+	return href, fi
+}
+
+func davReadDir(ctx context.Context, dir string) ([]os.DirEntry, error) {
+	// коллекции запрашиваем со слешем, иначе сервер может ответить редиректом
+	ms, err := davPropfind(ctx, strings.TrimSuffix(dir, "/")+"/", "1")
+	if err != nil {
+		return nil, err
+	}
+	_, self := splitRemote(dir)
+	self = strings.TrimSuffix(self, "/")
+	if self == "" {
+		self = "/"
+	}
+
+	davCacheMu.Lock()
+	defer davCacheMu.Unlock()
+	for k := range davCache {
+		if parentPath(k) == dir {
+			delete(davCache, k)
+		}
+	}
+	var entries []os.DirEntry
+	for _, r := range ms.Responses {
+		href, fi := davInfo(r)
+		if href == self {
+			continue
+		}
+		davCache[joinPath(dir, fi.name)] = fi
+		entries = append(entries, fs.FileInfoToDirEntry(fi))
+	}
+	return entries, nil
+}
+
+func davStat(ctx context.Context, p string) (os.FileInfo, error) {
+	davCacheMu.Lock()
+	fi, ok := davCache[p]
+	davCacheMu.Unlock()
+	if ok {
+		return fi, nil
+	}
+	ms, err := davPropfind(ctx, p, "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(ms.Responses) == 0 {
+		return nil, fmt.Errorf("%s: empty PROPFIND response", displayPath(p))
+	}
+	_, info := davInfo(ms.Responses[0])
+	info.name = baseName(p)
+	davCacheMu.Lock()
+	davCache[p] = info
+	davCacheMu.Unlock()
+	return info, nil
+}
+
+func davForget(p string) {
+	davCacheMu.Lock()
+	delete(davCache, p)
+	davCacheMu.Unlock()
+}
+
+func davDelete(ctx context.Context, p string) error {
+	resp, err := davDo(ctx, "DELETE", p, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	davForget(p)
+	return nil
+}
+
+// davTransfer выполняет MOVE или COPY в пределах сервера
+func davTransfer(ctx context.Context, method, src, dst string) error {
+	u, _, err := davURL(dst)
+	if err != nil {
+		return err
+	}
+	resp, err := davDo(ctx, method, src, nil, map[string]string{
+		"Destination": u.String(),
+		"Overwrite":   "F",
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func davMove(ctx context.Context, src, dst string) error {
+	if err := davTransfer(ctx, "MOVE", src, dst); err != nil {
+		return err
+	}
+	davForget(src)
+	return nil
+}
+
+func davCopy(ctx context.Context, src, dst string) error {
+	return davTransfer(ctx, "COPY", src, dst)
+}
+
+func davDownload(ctx context.Context, src, dst string) error {
+	info, err := davStat(ctx, src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		entries, err := davReadDir(ctx, src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := davDownload(ctx, joinPath(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	resp, err := davDo(ctx, "GET", src, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func davUpload(ctx context.Context, src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		resp, err := davDo(ctx, "MKCOL", dst, nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := davUpload(ctx, filepath.Join(src, e.Name()), joinPath(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	resp, err := davDo(ctx, "PUT", dst, f, map[string]string{
+		"Content-Length": strconv.FormatInt(info.Size(), 10),
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}